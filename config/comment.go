@@ -0,0 +1,94 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// CommentMarshaler is a Marshaler which could also write comments. The
+// comments are keyed by field paths like `db.host`.
+type CommentMarshaler interface {
+	MarshalComment(val interface{}, comments map[string]string) ([]byte, error)
+}
+
+// yamlComment encodes val to YAML with head comments.
+func yamlComment(val interface{}, comments map[string]string) (bin []byte, err error) {
+	node := yaml.Node{}
+	if err == nil {
+		err = node.Encode(val)
+	}
+	if err == nil {
+		var visit func(n *yaml.Node, path string)
+		visit = func(n *yaml.Node, path string) {
+			switch n.Kind {
+			case yaml.DocumentNode, yaml.SequenceNode:
+				for _, c := range n.Content {
+					visit(c, path)
+				}
+			case yaml.MappingNode:
+				for i := 0; i+1 < len(n.Content); i += 2 {
+					next := join(path, n.Content[i].Value)
+					if c, ok := comments[next]; ok {
+						n.Content[i].HeadComment = c
+					}
+					visit(n.Content[i+1], next)
+				}
+			}
+		}
+		visit(&node, "")
+		bin, err = yaml.Marshal(&node)
+	}
+	return
+}
+
+var (
+	tomlTable = regexp.MustCompile(`^\[\[?\s*(.+?)\s*\]\]?$`)
+	tomlKey   = regexp.MustCompile(`^("[^"]*"|[\w\-]+)\s*=`)
+)
+
+// tomlComment encodes val to TOML, then inserts comments above keys and
+// tables.
+func tomlComment(val interface{}, comments map[string]string) (bin []byte, err error) {
+	buf := new(bytes.Buffer)
+	if err == nil {
+		err = toml.NewEncoder(buf).Encode(val)
+	}
+	if err == nil {
+		out := new(bytes.Buffer)
+		table := ""
+		scan := bufio.NewScanner(buf)
+		for scan.Scan() {
+			line := scan.Text()
+			text := strings.TrimSpace(line)
+			path := ""
+			if m := tomlTable.FindStringSubmatch(text); m != nil {
+				table = strings.Replace(m[1], `"`, "", -1)
+				path = table
+			} else if m := tomlKey.FindStringSubmatch(text); m != nil {
+				path = join(table, strings.Trim(m[1], `"`))
+			}
+
+			if c, ok := comments[path]; ok && path != "" {
+				indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+				for _, l := range strings.Split(c, "\n") {
+					out.WriteString(indent + "# " + l + "\n")
+				}
+			}
+			out.WriteString(line + "\n")
+		}
+		bin, err = out.Bytes(), scan.Err()
+	}
+	return
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -3,6 +3,9 @@ package config
 import (
 	"bytes"
 	"encoding/json"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
 )
 
 // Marshaler for provide a set of method to Marshal and Unmarshal.
@@ -22,6 +25,21 @@ var (
 	Load           = defaults.Load
 	LoadSome       = defaults.LoadSome
 	LoadOrSaveSome = defaults.LoadOrSaveSome
+	LoadLayers     = defaults.LoadLayers
+	LoadReader     = defaults.LoadReader
+	SaveWriter     = defaults.SaveWriter
+	LoadFS         = defaults.LoadFS
+	LoadSomeFS     = defaults.LoadSomeFS
+	Example        = defaults.Example
+	Watch          = defaults.Watch
+	NewTree        = defaults.NewTree
+	LoadTree       = defaults.LoadTree
+	Edit           = defaults.Edit
+	LoadSource     = defaults.LoadSource
+	LoadSomeSource = defaults.LoadSomeSource
+	WatchSource    = defaults.WatchSource
+
+	RegisterMigration = migrations.Register
 )
 
 ////////////////////////////// the default //////////////////////////////////
@@ -43,6 +61,28 @@ var defaults = Marshalers{
 			return bin, err
 		},
 	},
+	&Wrapper{
+		CanEnc:  func(tag string, _ interface{}) bool { return tag == "yaml" || tag == "yml" },
+		CanDec:  func(tag string, ______ []byte) bool { return tag == "yaml" || tag == "yml" },
+		Decode:  yaml.Unmarshal,
+		Encode:  yaml.Marshal,
+		Comment: yamlComment,
+	},
+	&Wrapper{
+		CanEnc: func(tag string, _ interface{}) bool { return tag == "toml" },
+		CanDec: func(tag string, ______ []byte) bool { return tag == "toml" },
+		Decode: toml.Unmarshal,
+		Encode: func(val interface{}) ([]byte, error) {
+			buf := new(bytes.Buffer)
+			err := toml.NewEncoder(buf).Encode(val)
+			bin := buf.Bytes()
+			if err != nil {
+				bin = nil
+			}
+			return bin, err
+		},
+		Comment: tomlComment,
+	},
 }
 
 // Wrapper provide a simple way to create Marshaler.
@@ -51,6 +91,9 @@ type Wrapper struct {
 	CanDec func(tag string, bin []byte) bool
 	Encode func(val interface{}) ([]byte, error)
 	Decode func(bin []byte, val interface{}) error
+
+	// Comment is optional. It encodes with comments keyed by field paths.
+	Comment func(val interface{}, comments map[string]string) ([]byte, error)
 }
 
 // CanMarshal test if it can marshal val.
@@ -63,6 +106,15 @@ func (w *Wrapper) Marshal(val interface{}) ([]byte, error) {
 	return w.Encode(val)
 }
 
+// MarshalComment marshals object to bytes with comments. It falls back to
+// `Marshal` if `Comment` is nil.
+func (w *Wrapper) MarshalComment(val interface{}, comments map[string]string) ([]byte, error) {
+	if w.Comment == nil {
+		return w.Encode(val)
+	}
+	return w.Comment(val, comments)
+}
+
 // CanUnmarshal test if it can unmarshal.
 func (w *Wrapper) CanUnmarshal(tag string, bin []byte) bool {
 	return w.CanDec(tag, bin)
@@ -1,6 +1,7 @@
 package config_test
 
 import (
+	"errors"
 	"io/ioutil"
 	"os"
 	"testing"
@@ -41,3 +42,61 @@ func TestDefault(t *testing.T) {
 		assert.Equal(in.Message, out.Message)
 	}
 }
+
+func TestFormats(t *testing.T) {
+	assert := assert.New(t)
+
+	type Server struct {
+		Host string `json:"host" yaml:"host" toml:"host"`
+		Port int    `json:"port" yaml:"port" toml:"port"`
+	}
+	type Data struct {
+		Name    string            `json:"name"    yaml:"name"    toml:"name"`
+		Debug   bool              `json:"debug"   yaml:"debug"   toml:"debug"`
+		Ratio   float64           `json:"ratio"   yaml:"ratio"   toml:"ratio"`
+		Tags    []string          `json:"tags"    yaml:"tags"    toml:"tags"`
+		Labels  map[string]string `json:"labels"  yaml:"labels"  toml:"labels"`
+		Servers []Server          `json:"servers" yaml:"servers" toml:"servers"`
+	}
+
+	in := Data{
+		Name:    "orz",
+		Debug:   true,
+		Ratio:   0.5,
+		Tags:    []string{"a", "b"},
+		Labels:  map[string]string{"k": "v"},
+		Servers: []Server{{"localhost", 80}, {"example.com", 443}},
+	}
+
+	for _, ext := range []string{"json", "yaml", "yml", "toml", "YAML"} {
+		tmp, err := ioutil.TempFile("", "*."+ext)
+		assert.NoError(err)
+		if err != nil {
+			continue
+		}
+
+		dst := tmp.Name()
+		tmp.Close()
+		defer os.Remove(dst)
+
+		err = config.Save(&in, dst)
+		assert.NoError(err, ext)
+
+		out := Data{}
+		err = config.Load(&out, dst)
+		assert.NoError(err, ext)
+		assert.Equal(in, out, ext)
+	}
+
+	{ // unknown extension
+		tmp, err := ioutil.TempFile("", "*.ini")
+		assert.NoError(err)
+
+		dst := tmp.Name()
+		tmp.Close()
+		defer os.Remove(dst)
+
+		err = config.Save(&in, dst)
+		assert.True(errors.Is(err, config.ErrEncoding))
+	}
+}
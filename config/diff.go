@@ -0,0 +1,197 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Change is a field that differs between two configs.
+//  - Old or New is nil if the field is missing on that side.
+type Change struct {
+	Path string
+	Old  interface{}
+	New  interface{}
+}
+
+// Diff compares two configs and returns changed field paths with their
+// old and new values, sorted by path.
+//  - Paths are the same as `Validate` and `OptionEnv`, like `servers.0.port`.
+//  - Leaves, like strings, numbers and slices of them, are compared as a
+//    whole. Structs, maps and other slices are compared by their items.
+func Diff(old, new interface{}) []Change {
+	list := []Change{}
+	diff(reflect.ValueOf(old), reflect.ValueOf(new), nil, &list)
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	return list
+}
+
+func diff(a, b reflect.Value, path []string, out *[]Change) {
+	a, b = indirect(a), indirect(b)
+
+	report := func() {
+		if !reflect.DeepEqual(valueOf(a), valueOf(b)) {
+			*out = append(*out, Change{strings.Join(path, "."), valueOf(a), valueOf(b)})
+		}
+	}
+
+	switch {
+	case !a.IsValid() || !b.IsValid() || a.Type() != b.Type():
+		report()
+		return
+	case isLeaf(a.Type()):
+		report()
+		return
+	}
+
+	next := func(key string) []string {
+		return append(path[:len(path):len(path)], key)
+	}
+
+	switch a.Kind() {
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			f := a.Type().Field(i)
+			switch name := fieldName(f); {
+			case isInline(f):
+				diff(a.Field(i), b.Field(i), path, out)
+			case name != "":
+				diff(a.Field(i), b.Field(i), next(name), out)
+			}
+		}
+
+	case reflect.Map:
+		keys := map[string]reflect.Value{}
+		for _, m := range []reflect.Value{a, b} {
+			for _, k := range m.MapKeys() {
+				keys[fmt.Sprint(k.Interface())] = k
+			}
+		}
+		for _, name := range sortedValues(keys) {
+			k := keys[name]
+			diff(a.MapIndex(k), b.MapIndex(k), next(name), out)
+		}
+
+	case reflect.Slice, reflect.Array:
+		n := a.Len()
+		if b.Len() > n {
+			n = b.Len()
+		}
+		for i := 0; i < n; i++ {
+			var x, y reflect.Value
+			if i < a.Len() {
+				x = a.Index(i)
+			}
+			if i < b.Len() {
+				y = b.Index(i)
+			}
+			diff(x, y, next(strconv.Itoa(i)), out)
+		}
+
+	default:
+		report()
+	}
+}
+
+// indirect dereferences pointers and interfaces. It returns an invalid
+// value if any of them is nil.
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// valueOf returns the value in v, or nil if v is invalid.
+func valueOf(v reflect.Value) interface{} {
+	if !v.IsValid() || !v.CanInterface() {
+		return nil
+	}
+	return v.Interface()
+}
+
+func sortedValues(m map[string]reflect.Value) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Subscribers calls functions subscribed to field paths when they change.
+// It is safe for concurrent use. The zero value is ready to use.
+type Subscribers struct {
+	lock sync.Mutex
+	list []subscriber
+}
+
+type subscriber struct {
+	pattern []string
+	fn      func([]Change)
+}
+
+// Subscribe registers fn to be called with changes under pattern.
+//  - A pattern is a field path, where "*" matches any one segment, like
+//    `servers.*.port`.
+//  - A pattern also matches all its children, so `db` and `db.*` are the
+//    same. An empty pattern or "*" matches everything.
+//  - A change of a parent, like `db` from nil to something, also matches.
+func (s *Subscribers) Subscribe(pattern string, fn func(changes []Change)) {
+	if fn == nil {
+		return
+	}
+
+	list := strings.Split(pattern, ".")
+	for len(list) != 0 && (list[len(list)-1] == "*" || list[len(list)-1] == "") {
+		list = list[:len(list)-1]
+	}
+
+	defer s.lock.Unlock()
+	/*_*/ s.lock.Lock()
+	s.list = append(s.list, subscriber{list, fn})
+}
+
+// Notify calls each subscribed function with the changes it matches.
+// Functions without matched changes are not called.
+func (s *Subscribers) Notify(changes []Change) {
+	if len(changes) == 0 {
+		return
+	}
+
+	s.lock.Lock()
+	list := s.list
+	s.lock.Unlock()
+
+	for _, sub := range list {
+		var some []Change
+		for _, c := range changes {
+			if sub.match(c.Path) {
+				some = append(some, c)
+			}
+		}
+		if len(some) != 0 {
+			sub.fn(some)
+		}
+	}
+}
+
+func (s *subscriber) match(path string) bool {
+	list := []string{}
+	if path != "" {
+		list = strings.Split(path, ".")
+	}
+
+	for i := 0; i < len(s.pattern) && i < len(list); i++ {
+		if s.pattern[i] != "*" && s.pattern[i] != list[i] {
+			return false
+		}
+	}
+	return true
+}
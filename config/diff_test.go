@@ -0,0 +1,93 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/wiryls/pkg/config"
+)
+
+func TestDiff(t *testing.T) {
+	assert := assert.New(t)
+
+	type DB struct {
+		Host    string        `json:"host"`
+		Timeout time.Duration `json:"timeout"`
+	}
+	type Server struct {
+		Port int `json:"port"`
+	}
+	type Data struct {
+		Name    string            `json:"name"`
+		Tags    []string          `json:"tags"`
+		DB      *DB               `json:"db"`
+		Servers []Server          `json:"servers"`
+		Labels  map[string]string `json:"labels"`
+		skipped int
+	}
+
+	a := &Data{
+		Name:    "orz",
+		Tags:    []string{"a"},
+		DB:      &DB{Host: "localhost", Timeout: time.Second},
+		Servers: []Server{{80}},
+		Labels:  map[string]string{"a": "1", "b": "2"},
+		skipped: 1,
+	}
+
+	{ // same
+		assert.Empty(config.Diff(a, a))
+		assert.Empty(config.Diff(nil, nil))
+	}
+	{ // changed
+		b := &Data{
+			Name:    "orz",
+			Tags:    []string{"a", "b"},
+			DB:      &DB{Host: "localhost", Timeout: 2 * time.Second},
+			Servers: []Server{{80}, {443}},
+			Labels:  map[string]string{"a": "0", "c": "3"},
+		}
+		assert.Equal([]config.Change{
+			{Path: "db.timeout", Old: time.Second, New: 2 * time.Second},
+			{Path: "labels.a", Old: "1", New: "0"},
+			{Path: "labels.b", Old: "2", New: nil},
+			{Path: "labels.c", Old: nil, New: "3"},
+			{Path: "servers.1", Old: nil, New: Server{443}},
+			{Path: "tags", Old: []string{"a"}, New: []string{"a", "b"}},
+		}, config.Diff(a, b))
+	}
+	{ // nil
+		b := &Data{Name: "orz", Tags: a.Tags, Servers: a.Servers, Labels: a.Labels}
+		assert.Equal([]config.Change{
+			{Path: "db", Old: *a.DB, New: nil},
+		}, config.Diff(a, b))
+	}
+}
+
+func TestSubscribers(t *testing.T) {
+	assert := assert.New(t)
+
+	got := map[string][]config.Change{}
+	s := config.Subscribers{}
+	for _, pattern := range []string{"db.*", "db.host", "servers.*.port", "", "http"} {
+		pattern := pattern
+		s.Subscribe(pattern, func(changes []config.Change) { got[pattern] = changes })
+	}
+
+	changes := []config.Change{
+		{Path: "db.host", Old: "a", New: "b"},
+		{Path: "db.port", Old: 1, New: 2},
+		{Path: "servers.1", Old: nil, New: 443},
+		{Path: "servers.0.port", Old: 80, New: 8080},
+		{Path: "servers.0.host", Old: "a", New: "b"},
+	}
+	s.Notify(changes)
+
+	assert.Equal(changes[:2], got["db.*"])
+	assert.Equal(changes[:1], got["db.host"])
+	assert.Equal(changes[2:4], got["servers.*.port"])
+	assert.Equal(changes, got[""])
+	assert.NotContains(got, "http")
+}
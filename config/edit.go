@@ -0,0 +1,398 @@
+package config
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/wiryls/pkg/errors/cerrors"
+	"github.com/wiryls/pkg/errors/wrap"
+)
+
+// OptionKeepFormat makes `Save` edit an existing file in place like
+// `Editor` does, so comments, blank lines and the order of keys are kept.
+//  - It writes a new file as usual if dst is missing or broken.
+func OptionKeepFormat() Option {
+	return func(o *options) { o.keep = true }
+}
+
+// Editor edits values of a config file by field paths, and keeps the rest
+// of the file as it is.
+//  - YAML and TOML keep comments, blank lines and the order of keys in
+//    untouched regions. JSON keeps its order and indentation.
+//  - Each edit is verified by decoding the result. If a value cannot be
+//    edited in place, its parent is rewritten instead, and the whole file
+//    at worst. It still succeeds, but comments and styles of the rewritten
+//    part are lost. For example, editing a YAML value shared by anchors or
+//    merge keys expands all of them, and appending to a flow sequence
+//    rewrites it in the block style.
+//  - The file is used as it is, that is, `$include` is not expanded and
+//    migrations are not applied.
+type Editor struct {
+	name string
+	tag  string
+	bin  []byte
+	from Marshalers
+	text textEditor
+}
+
+// textEditor edits the text of a format in place. Each method returns
+// false if it cannot do it.
+type textEditor interface {
+	// replace the value at path with val.
+	replace(bin []byte, path []string, val interface{}) ([]byte, bool)
+	// insert key with val into the map at path, or append val to the
+	// sequence at path if key is its length.
+	insert(bin []byte, path []string, key string, val interface{}) ([]byte, bool)
+	// remove the value at path.
+	remove(bin []byte, path []string) ([]byte, bool)
+}
+
+// noText is used by formats without a textEditor. It always fails.
+type noText struct{}
+
+func (noText) replace([]byte, []string, interface{}) ([]byte, bool)        { return nil, false }
+func (noText) insert([]byte, []string, string, interface{}) ([]byte, bool) { return nil, false }
+func (noText) remove([]byte, []string) ([]byte, bool)                      { return nil, false }
+
+// Edit opens src to edit. Call `Save` to write changes back.
+func (s Marshalers) Edit(src string) (e *Editor, err error) {
+	var bin []byte
+	if err == nil {
+		bin, err = ioutil.ReadFile(src)
+		err = wrap.MessageAliasStack(err, "cannot read `"+src+"`", ErrReading, 0)
+	}
+
+	if err == nil {
+		_, err = s.toTree(src, bin)
+	}
+
+	if err == nil {
+		e = s.editor(src, s.format(src, bin), bin)
+	}
+
+	return
+}
+
+// editor creates an `Editor` of bin in format tag.
+func (s Marshalers) editor(name, tag string, bin []byte) *Editor {
+	e := &Editor{name: name, tag: tag, bin: bin, from: s}
+	switch e.tag {
+	case "json":
+		e.text = jsonText{}
+	case "yaml", "yml":
+		e.text = yamlText{}
+	case "toml":
+		e.text = tomlText{}
+	default:
+		e.text = noText{}
+	}
+	return e
+}
+
+// Bytes returns the edited content.
+func (e *Editor) Bytes() []byte {
+	return e.bin
+}
+
+// Set stores val at path. Missing maps on the path are created.
+//  - An index of a slice must be in range, or be its length to append.
+//  - Maps are merged key by key, so that untouched keys keep formatting.
+func (e *Editor) Set(path string, val interface{}) (err error) {
+	keys := split(path)
+	err = cerrors.TestInvalidArgument(len(keys) == 0, "path string", "must not be empty")
+
+	if err == nil {
+		val, err = generic(val)
+	}
+
+	var want *Tree
+	if err == nil {
+		want, err = e.tree()
+	}
+	if err == nil {
+		err = want.Set(path, val)
+	}
+
+	if err == nil {
+		err = e.apply(want, keys, func(bin []byte) ([]byte, bool) {
+			return e.set(bin, keys, val)
+		})
+	}
+
+	return
+}
+
+// Delete removes the value at path. Nothing happens if it is missing.
+func (e *Editor) Delete(path string) (err error) {
+	keys := split(path)
+	err = cerrors.TestInvalidArgument(len(keys) == 0, "path string", "must not be empty")
+
+	var want *Tree
+	if err == nil {
+		want, err = e.tree()
+	}
+
+	if err == nil && want.Delete(path) {
+		err = e.apply(want, keys, func(bin []byte) ([]byte, bool) {
+			return e.text.remove(bin, keys)
+		})
+	}
+
+	return
+}
+
+// Save writes the content back to the file atomically.
+func (e *Editor) Save(opts ...Option) error {
+	return e.save(collect(opts))
+}
+
+func (e *Editor) save(o *options) error {
+	return writeFile(e.name, o, func(w io.Writer) error {
+		_, err := w.Write(e.bin)
+		return wrap.MessageAliasStack(err, "cannot write `"+e.name+"`", ErrWriting, 0)
+	})
+}
+
+// update makes the file hold src by editing it.
+func (e *Editor) update(src interface{}, o *options) (err error) {
+	var bin []byte
+	if err == nil {
+		c := *o
+		c.keep = false
+		bin, err = e.from.toBytes(nameOf(e.tag), src, &c)
+	}
+
+	var tree map[string]interface{}
+	if err == nil {
+		tree, err = e.from.toTree(nameOf(e.tag), bin)
+	}
+
+	if err == nil {
+		want := e.from.treeOf(e.tag, bin, tree)
+		err = e.apply(want, nil, func(bin []byte) ([]byte, bool) {
+			return e.set(bin, nil, tree)
+		})
+	}
+
+	return
+}
+
+// tree decodes the current content.
+func (e *Editor) tree() (*Tree, error) {
+	tree, err := e.from.toTree(nameOf(e.tag), e.bin)
+	if err != nil {
+		return nil, err
+	}
+	return e.from.treeOf(e.tag, e.bin, tree), nil
+}
+
+// apply tries edit first, then rewrites path and its parents one by one, and
+// finally the whole file, until the content decodes to want.
+func (e *Editor) apply(want *Tree, path []string, edit func([]byte) ([]byte, bool)) (err error) {
+	check := func(bin []byte, ok bool) bool {
+		if ok {
+			if tree, err := e.from.toTree(nameOf(e.tag), bin); err == nil && same(tree, want.root) {
+				e.bin = bin
+				return true
+			}
+		}
+		return false
+	}
+
+	if check(edit(e.bin)) {
+		return nil
+	}
+
+	for i := len(path); i > 0; i-- {
+		if val, ok := want.Get(strings.Join(path[:i], ".")); ok {
+			if check(e.text.replace(e.bin, path[:i], val)) {
+				return nil
+			}
+		}
+	}
+
+	var bin []byte
+	bin, err = e.from.toBytes(nameOf(e.tag), want.value(nameOf(e.tag)), collect(nil))
+	if err == nil && !check(bin, true) {
+		err = wrap.MessageStack(ErrEncoding, "cannot edit `"+e.name+"`", 0)
+	}
+	return
+}
+
+// set stores val at path of bin. Maps and slices of the same length are
+// set item by item.
+func (e *Editor) set(bin []byte, path []string, val interface{}) ([]byte, bool) {
+	tree, err := e.from.toTree(nameOf(e.tag), bin)
+	if err != nil {
+		return nil, false
+	}
+
+	old, ok := treeGet(tree, path)
+	if !ok {
+		// insert into the nearest map, or append to the nearest sequence
+		for i := len(path) - 1; i >= 0; i-- {
+			if parent, ok := treeGet(tree, path[:i]); ok {
+				switch p := parent.(type) {
+				case map[string]interface{}:
+				case []interface{}:
+					if path[i] != strconv.Itoa(len(p)) {
+						return nil, false
+					}
+				default:
+					return nil, false
+				}
+				for j := len(path) - 1; j > i; j-- {
+					val = map[string]interface{}{path[j]: val}
+				}
+				return e.text.insert(bin, path[:i], path[i], val)
+			}
+		}
+		return nil, false
+	}
+
+	next := func(k string) []string {
+		return append(path[:len(path):len(path)], k)
+	}
+
+	switch {
+	case same(old, val):
+		return bin, true
+
+	case isMap(old) && isMap(val):
+		o, v := old.(map[string]interface{}), val.(map[string]interface{})
+		for _, k := range sortedKeys(v) {
+			if bin, ok = e.set(bin, next(k), v[k]); !ok {
+				return nil, false
+			}
+		}
+		for _, k := range sortedKeys(o) {
+			if _, has := v[k]; !has {
+				if bin, ok = e.text.remove(bin, next(k)); !ok {
+					return nil, false
+				}
+			}
+		}
+		return bin, true
+
+	case isList(old) && isList(val) && len(old.([]interface{})) == len(val.([]interface{})):
+		for i, x := range val.([]interface{}) {
+			if bin, ok = e.set(bin, next(strconv.Itoa(i)), x); !ok {
+				return nil, false
+			}
+		}
+		return bin, true
+
+	case len(path) == 0:
+		return nil, false
+
+	default:
+		return e.text.replace(bin, path, val)
+	}
+}
+
+func isMap(x interface{}) bool {
+	_, ok := x.(map[string]interface{})
+	return ok
+}
+
+func isList(x interface{}) bool {
+	_, ok := x.([]interface{})
+	return ok
+}
+
+// treeGet finds the value at path in a generic tree.
+func treeGet(tree map[string]interface{}, path []string) (interface{}, bool) {
+	t := Tree{root: tree}
+	return t.Get(strings.Join(path, "."))
+}
+
+// same compares two generic trees, where numbers of the same value are
+// equal.
+func same(a, b interface{}) bool {
+	return reflect.DeepEqual(canonical(a), canonical(b))
+}
+
+func canonical(x interface{}) interface{} {
+	switch v := x.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = canonical(e)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			l[i] = canonical(e)
+		}
+		return l
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v)
+		}
+		return v
+	}
+
+	switch v := reflect.ValueOf(x); v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Uint() <= math.MaxInt64 {
+			return int64(v.Uint())
+		}
+	case reflect.Float32:
+		return canonical(v.Float())
+	}
+	return x
+}
+
+// splice replaces bin[start:end] with text in a new slice.
+func splice(bin []byte, start, end int, text string) []byte {
+	out := make([]byte, 0, len(bin)-(end-start)+len(text))
+	out = append(out, bin[:start]...)
+	out = append(out, text...)
+	return append(out, bin[end:]...)
+}
+
+// lineStart returns the offset of the start of the line at offset i.
+func lineStart(bin []byte, i int) int {
+	return bytes.LastIndexByte(bin[:i], '\n') + 1
+}
+
+// lineEnd returns the offset of the "\n" of the line at offset i, or the
+// length of bin if there is none.
+func lineEnd(bin []byte, i int) int {
+	if n := bytes.IndexByte(bin[i:], '\n'); n >= 0 {
+		return i + n
+	}
+	return len(bin)
+}
+
+// indentOf returns the leading spaces of the line at offset i.
+func indentOf(bin []byte, i int) string {
+	head := lineStart(bin, i)
+	line := bin[head:lineEnd(bin, head)]
+	return string(line[:len(line)-len(bytes.TrimLeft(line, " \t"))])
+}
+
+// indentUnit guesses the unit of indentation of bin by the smallest one.
+// It returns def if there is no indented line.
+func indentUnit(bin []byte, def string) string {
+	unit := ""
+	for _, line := range bytes.Split(bin, []byte("\n")) {
+		body := bytes.TrimLeft(line, " \t")
+		n := len(line) - len(body)
+		if n > 0 && len(bytes.TrimSpace(body)) != 0 && (unit == "" || n < len(unit)) {
+			unit = string(line[:n])
+		}
+	}
+	if unit == "" {
+		unit = def
+	}
+	return unit
+}
@@ -0,0 +1,280 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// jsonText edits JSON in place. It keeps the order of keys and the
+// indentation of untouched values.
+type jsonText struct{}
+
+// jsonNode is a JSON value with its span in the text.
+type jsonNode struct {
+	start int
+	end   int
+	keys  []string    // keys of an object, nil for others
+	heads []int       // starts of keys of an object, or items of an array
+	items []*jsonNode // values of an object or an array
+	array bool
+}
+
+func (jsonText) replace(bin []byte, path []string, val interface{}) ([]byte, bool) {
+	n, p, _ := findJSON(bin, path)
+	if n == nil {
+		return nil, false
+	}
+	if p == nil || n.keys != nil || n.array {
+		p = n
+	}
+
+	text, ok := "", false
+	if bytes.IndexByte(bin[p.start:p.end], '\n') < 0 {
+		// keep it in one line
+		text, ok = compactJSON(val)
+	} else {
+		text, ok = encodeJSON(val, indentOf(bin, n.start), indentUnit(bin, "    "))
+	}
+	if !ok {
+		return nil, false
+	}
+	return splice(bin, n.start, n.end, text), true
+}
+
+func (jsonText) insert(bin []byte, path []string, key string, val interface{}) ([]byte, bool) {
+	p, _, _ := findJSON(bin, path)
+	if p == nil || len(p.items) == 0 || p.array && key != strconv.Itoa(len(p.items)) {
+		return nil, false
+	}
+
+	last := len(p.items) - 1
+	head, end := p.heads[last], p.items[last].end
+
+	// arrays have no keys
+	name := ""
+	if !p.array {
+		text, _ := json.Marshal(key)
+		name = string(text) + ": "
+	}
+
+	text := ""
+	if bytes.IndexByte(bin[p.start:head], '\n') < 0 {
+		// all in one line
+		v, ok := compactJSON(val)
+		if !ok {
+			return nil, false
+		}
+		text = ", " + name + v
+	} else {
+		indent := indentOf(bin, head)
+		v, ok := encodeJSON(val, indent, indentUnit(bin, "    "))
+		if !ok {
+			return nil, false
+		}
+		text = ",\n" + indent + name + v
+	}
+	return splice(bin, end, end, text), true
+}
+
+func (jsonText) remove(bin []byte, path []string) ([]byte, bool) {
+	_, p, i := findJSON(bin, path)
+	if p == nil || len(p.items) < 2 {
+		return nil, false
+	}
+
+	if i == 0 {
+		return splice(bin, p.heads[0], p.heads[1], ""), true
+	}
+	return splice(bin, p.items[i-1].end, p.items[i].end, ""), true
+}
+
+// findJSON finds the node at path, its parent and its index in parent.
+func findJSON(bin []byte, path []string) (n, parent *jsonNode, i int) {
+	p := jsonParser{bin: bin}
+	n = p.value()
+	if n == nil {
+		return nil, nil, 0
+	}
+
+	for _, k := range path {
+		parent, i = n, -1
+		if n.array {
+			if j, ok := index(k, len(n.items)); ok {
+				i = j
+			}
+		} else {
+			for j, key := range n.keys {
+				if key == k {
+					i = j // the last one wins
+				}
+			}
+		}
+		if i < 0 {
+			return nil, nil, 0
+		}
+		n = n.items[i]
+	}
+	return
+}
+
+// encodeJSON encodes val with each new line starting with prefix.
+func encodeJSON(val interface{}, prefix, unit string) (string, bool) {
+	buf := bytes.Buffer{}
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent(prefix, unit)
+	if err := enc.Encode(val); err != nil {
+		return "", false
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), true
+}
+
+// compactJSON encodes val in one line, like `{"a": 1, "b": [2]}`.
+func compactJSON(val interface{}) (string, bool) {
+	text, ok := encodeJSON(val, "", "\t")
+	if !ok {
+		return "", false
+	}
+
+	// strings never contain raw new lines or tabs
+	lines := strings.Split(text, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimLeft(lines[i], "\t")
+	}
+	for i := 1; i < len(lines); i++ {
+		prev, line := lines[i-1], lines[i]
+		switch {
+		case strings.HasSuffix(prev, "{"), strings.HasSuffix(prev, "["):
+		case strings.HasPrefix(line, "}"), strings.HasPrefix(line, "]"):
+		default:
+			lines[i] = " " + line
+		}
+	}
+	return strings.Join(lines, ""), true
+}
+
+// jsonParser parses JSON into nodes. It returns nil for invalid input.
+type jsonParser struct {
+	bin []byte
+	i   int
+}
+
+func (p *jsonParser) value() *jsonNode {
+	p.space()
+	if p.i >= len(p.bin) {
+		return nil
+	}
+
+	n := &jsonNode{start: p.i}
+	switch p.bin[p.i] {
+	case '{':
+		n.keys = []string{}
+		if !p.list('}', func() bool {
+			head := p.i
+			if !p.string() {
+				return false
+			}
+
+			var key string
+			if json.Unmarshal(p.bin[head:p.i], &key) != nil {
+				return false
+			}
+
+			p.space()
+			if !p.next(':') {
+				return false
+			}
+
+			v := p.value()
+			if v == nil {
+				return false
+			}
+
+			n.keys = append(n.keys, key)
+			n.heads = append(n.heads, head)
+			n.items = append(n.items, v)
+			return true
+		}) {
+			return nil
+		}
+
+	case '[':
+		n.array = true
+		if !p.list(']', func() bool {
+			v := p.value()
+			if v == nil {
+				return false
+			}
+
+			n.heads = append(n.heads, v.start)
+			n.items = append(n.items, v)
+			return true
+		}) {
+			return nil
+		}
+
+	case '"':
+		if !p.string() {
+			return nil
+		}
+
+	default:
+		for p.i < len(p.bin) && !strings.ContainsRune(",:]} \t\r\n", rune(p.bin[p.i])) {
+			p.i++
+		}
+	}
+
+	n.end = p.i
+	return n
+}
+
+// list parses items separated by "," until the closing byte.
+func (p *jsonParser) list(closing byte, item func() bool) bool {
+	p.i++
+	for first := true; ; first = false {
+		p.space()
+		if p.next(closing) {
+			return true
+		}
+		if !first && !p.next(',') {
+			return false
+		}
+		p.space()
+		if !item() {
+			return false
+		}
+	}
+}
+
+func (p *jsonParser) string() bool {
+	if !p.next('"') {
+		return false
+	}
+	for p.i < len(p.bin) {
+		switch p.bin[p.i] {
+		case '\\':
+			p.i += 2
+		case '"':
+			p.i++
+			return true
+		default:
+			p.i++
+		}
+	}
+	return false
+}
+
+func (p *jsonParser) next(b byte) bool {
+	if p.i < len(p.bin) && p.bin[p.i] == b {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *jsonParser) space() {
+	for p.i < len(p.bin) && strings.ContainsRune(" \t\r\n", rune(p.bin[p.i])) {
+		p.i++
+	}
+}
@@ -0,0 +1,329 @@
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/wiryls/pkg/config"
+)
+
+func TestEdit(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	for _, c := range []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			"config.yaml",
+			`# the server
+server:
+  host: localhost # where to listen
+  port: 80
+
+  # timeouts
+  timeout: 5s
+tags:
+  - a
+  - b
+debug: true
+`,
+			`# the server
+server:
+  host: 0.0.0.0 # where to listen
+  port: 8080
+
+  # timeouts
+  timeout: 5s
+  tls:
+    cert: a.pem
+tags:
+  - a
+  - c
+`,
+		},
+		{
+			"config.toml",
+			`# the server
+debug = true
+
+[server]
+host = "localhost" # where to listen
+port = 80
+
+# timeouts
+timeout = "5s"
+
+[tags]
+list = ["a", "b"]
+`,
+			`# the server
+
+[server]
+host = "0.0.0.0" # where to listen
+port = 8080
+
+# timeouts
+timeout = "5s"
+tls.cert = "a.pem"
+
+[tags]
+list = ["a", "c"]
+`,
+		},
+		{
+			"config.json",
+			`{
+  "server": {
+    "host": "localhost",
+    "port": 80,
+    "timeout": "5s"
+  },
+  "tags": ["a", "b"],
+  "debug": true
+}
+`,
+			`{
+  "server": {
+    "host": "0.0.0.0",
+    "port": 8080,
+    "timeout": "5s",
+    "tls": {
+      "cert": "a.pem"
+    }
+  },
+  "tags": ["a", "c"]
+}
+`,
+		},
+	} {
+		src := filepath.Join(dir, c.name)
+		assert.NoError(ioutil.WriteFile(src, []byte(c.text), 0644))
+
+		e, err := config.Edit(src)
+		if !assert.NoError(err, c.name) {
+			continue
+		}
+
+		tags := "tags.1"
+		if filepath.Ext(c.name) == ".toml" {
+			tags = "tags.list.1"
+		}
+
+		assert.NoError(e.Set("server.host", "0.0.0.0"), c.name)
+		assert.NoError(e.Set("server.port", 8080), c.name)
+		assert.NoError(e.Set("server.tls", map[string]string{"cert": "a.pem"}), c.name)
+		assert.NoError(e.Set(tags, "c"), c.name)
+		assert.NoError(e.Delete("debug"), c.name)
+		assert.NoError(e.Delete("nothing"), c.name)
+		assert.Error(e.Set("", 1), c.name)
+		assert.Equal(c.want, string(e.Bytes()), c.name)
+
+		assert.NoError(e.Save(), c.name)
+		bin, err := ioutil.ReadFile(src)
+		assert.NoError(err, c.name)
+		assert.Equal(c.want, string(bin), c.name)
+	}
+}
+
+func TestSaveKeepFormat(t *testing.T) {
+	assert := assert.New(t)
+
+	type Data struct {
+		Name string `yaml:"name"`
+		Port int    `yaml:"port"`
+	}
+
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "config.yaml")
+	assert.NoError(ioutil.WriteFile(src, []byte("# hello\nport: 80 # port\n\nname: orz\nextra: 1\n"), 0644))
+
+	{ // edited
+		assert.NoError(config.Save(&Data{Name: "orz", Port: 8080}, src, config.OptionKeepFormat()))
+		bin, err := ioutil.ReadFile(src)
+		assert.NoError(err)
+		assert.Equal("# hello\nport: 8080 # port\n\nname: orz\n", string(bin))
+	}
+	{ // missing
+		dst := filepath.Join(dir, "new.yaml")
+		assert.NoError(config.Save(&Data{Name: "orz", Port: 80}, dst, config.OptionKeepFormat()))
+		out := Data{}
+		assert.NoError(config.Load(&out, dst))
+		assert.Equal(Data{Name: "orz", Port: 80}, out)
+	}
+}
+
+func TestEditAnchor(t *testing.T) {
+	assert := assert.New(t)
+
+	type Server struct {
+		Host string `yaml:"host"`
+		Port int    `yaml:"port"`
+	}
+	type Data struct {
+		Base Server `yaml:"base"`
+		Prod Server `yaml:"prod"`
+	}
+
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "config.yaml")
+	assert.NoError(ioutil.WriteFile(src, []byte(`# base
+base: &b
+  host: localhost # h
+  port: 80
+
+prod:
+  <<: *b
+  port: 443 # p
+`), 0644))
+
+	e, err := config.Edit(src)
+	assert.NoError(err)
+
+	{ // not shared, edited in place
+		assert.NoError(e.Set("prod.port", 8443))
+		assert.Equal(`# base
+base: &b
+  host: localhost # h
+  port: 80
+
+prod:
+  <<: *b
+  port: 8443 # p
+`, string(e.Bytes()))
+	}
+	{ // shared, anchors are expanded
+		assert.NoError(e.Set("base.host", "0.0.0.0"))
+		assert.NoError(e.Save())
+
+		bin, err := ioutil.ReadFile(src)
+		assert.NoError(err)
+		assert.NotContains(string(bin), "&b")
+		assert.NotContains(string(bin), "#")
+
+		out := Data{}
+		assert.NoError(config.Load(&out, src))
+		assert.Equal(Data{Server{"0.0.0.0", 80}, Server{"localhost", 8443}}, out)
+	}
+}
+
+func TestEditAppend(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	for _, c := range []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			"config.yaml",
+			`servers:
+  - host: a # h
+    port: 80
+  - {host: b, port: 81}
+tags:
+  - x
+`,
+			`servers:
+  - host: a # h
+    port: 80
+  - {host: b, port: 81}
+  - host: c
+    port: 82
+tags:
+  - x
+  - "y"
+`,
+		},
+		{
+			"config.json",
+			`{
+  "servers": [
+    {"host": "a", "port": 80},
+    {"host": "b", "port": 81}
+  ],
+  "tags": ["x"]
+}
+`,
+			`{
+  "servers": [
+    {"host": "a", "port": 80},
+    {"host": "b", "port": 81},
+    {
+      "host": "c",
+      "port": 82
+    }
+  ],
+  "tags": ["x", "y"]
+}
+`,
+		},
+		{
+			"config.toml",
+			`tags = ["x"] # t
+
+# servers
+[[servers]]
+host = "a" # h
+port = 80
+
+[[servers]]
+host = "b"
+port = 81
+
+[other]
+name = "o"
+`,
+			`tags = ["x", "y"] # t
+
+# servers
+[[servers]]
+host = "a" # h
+port = 80
+
+[[servers]]
+host = "b"
+port = 81
+
+[[servers]]
+host = "c"
+port = 82
+
+[other]
+name = "o"
+`,
+		},
+	} {
+		src := filepath.Join(dir, c.name)
+		assert.NoError(ioutil.WriteFile(src, []byte(c.text), 0644))
+
+		e, err := config.Edit(src)
+		if !assert.NoError(err, c.name) {
+			continue
+		}
+
+		assert.NoError(e.Set("servers.2", map[string]interface{}{"host": "c", "port": 82}), c.name)
+		assert.NoError(e.Set("tags.1", "y"), c.name)
+		assert.Error(e.Set("tags.3", "z"), c.name)
+		assert.Equal(c.want, string(e.Bytes()), c.name)
+	}
+}
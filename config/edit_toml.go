@@ -0,0 +1,468 @@
+package config
+
+import (
+	"bytes"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// tomlText edits TOML in place. It keeps comments, blank lines and the
+// order of keys and tables. Values inside inline tables and arrays are
+// not edited in place.
+type tomlText struct{}
+
+// tomlLine is a line of a key or a table header.
+type tomlLine struct {
+	path   string // full path, with indexes of arrays of tables
+	table  string // path of the table it belongs to
+	header bool
+	head   int // start of the line
+	start  int // start of the value
+	end    int // end of the value
+	next   int // start of the next line
+}
+
+func (tomlText) replace(bin []byte, path []string, val interface{}) ([]byte, bool) {
+	list, ok := scanTOML(bin)
+	if !ok {
+		return nil, false
+	}
+
+	key := strings.Join(path, ".")
+	for _, l := range list {
+		if l.path == key && !l.header {
+			if text, ok := tomlInline(val); ok {
+				return splice(bin, l.start, l.end, text), true
+			}
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+func (tomlText) insert(bin []byte, path []string, key string, val interface{}) ([]byte, bool) {
+	list, ok := scanTOML(bin)
+	if !ok {
+		return nil, false
+	}
+
+	// the table to hold the new key, and where to put it
+	parent := strings.Join(path, ".")
+	if n, err := strconv.Atoi(key); err == nil && n > 0 {
+		if bin, ok := appendTOML(bin, list, parent, n, val); ok {
+			return bin, true
+		}
+	}
+	table, at := "", 0
+	for _, l := range list {
+		switch {
+		case !l.header && (l.path == parent || strings.HasPrefix(parent, l.path+".")):
+			return nil, false // an inline table or an array
+		case l.header && l.path == parent:
+			table, at = l.path, l.next
+		case l.header && strings.HasPrefix(parent, l.path+".") && len(l.path) > len(table):
+			table, at = l.path, l.next
+		}
+	}
+	for _, l := range list {
+		if !l.header && l.table == table && l.next > at {
+			at = l.next
+		}
+	}
+
+	prefix := []string{}
+	if rel := strings.TrimPrefix(strings.TrimPrefix(parent, table), "."); rel != "" {
+		prefix = strings.Split(rel, ".")
+	}
+
+	lines := []string{}
+	if !tomlLines(append(prefix, key), val, &lines) {
+		return nil, false
+	}
+
+	text := strings.Join(lines, "\n") + "\n"
+	switch {
+	case at > 0 && bin[at-1] != '\n':
+		text = "\n" + text
+	case at == 0 && len(list) != 0 && list[0].header:
+		text += "\n"
+	}
+	return splice(bin, at, at, text), true
+}
+
+// appendTOML appends val as the table n to the array of tables at parent.
+func appendTOML(bin []byte, list []tomlLine, parent string, n int, val interface{}) ([]byte, bool) {
+	m, ok := val.(map[string]interface{})
+	if !ok || len(m) == 0 {
+		return nil, false
+	}
+
+	// after the last table and its sub tables
+	last, header, at := join(parent, strconv.Itoa(n-1)), "", 0
+	for _, l := range list {
+		switch {
+		case l.path == join(parent, strconv.Itoa(n)):
+			return nil, false
+		case l.header && l.path == last:
+			header = strings.TrimLeft(string(bin[l.head:l.start]), " \t")
+		case l.table == last || strings.HasPrefix(l.table, last+"."):
+		default:
+			continue
+		}
+		if l.next > at {
+			at = l.next
+		}
+	}
+	if !strings.HasPrefix(header, "[[") {
+		return nil, false
+	}
+
+	lines := []string{header + "]]"}
+	if !tomlLines(nil, m, &lines) {
+		return nil, false
+	}
+
+	text := "\n" + strings.Join(lines, "\n") + "\n"
+	if bin[at-1] != '\n' {
+		text = "\n" + text
+	}
+	return splice(bin, at, at, text), true
+}
+
+func (tomlText) remove(bin []byte, path []string) ([]byte, bool) {
+	list, ok := scanTOML(bin)
+	if !ok {
+		return nil, false
+	}
+
+	// remove lines of keys and whole tables under path
+	key := strings.Join(path, ".")
+	cuts := [][2]int{}
+	for i, l := range list {
+		if l.path != key && !strings.HasPrefix(l.path, key+".") {
+			continue
+		}
+		if !l.header {
+			cuts = append(cuts, [2]int{l.head, l.next})
+			continue
+		}
+
+		end := len(bin)
+		for _, n := range list[i+1:] {
+			if n.header {
+				end = n.head
+				break
+			}
+		}
+		cuts = append(cuts, [2]int{l.head, end})
+	}
+	if len(cuts) == 0 {
+		return nil, false
+	}
+
+	// merge overlapped ones, and cut from the end
+	sort.Slice(cuts, func(i, j int) bool { return cuts[i][0] < cuts[j][0] })
+	merged := cuts[:1]
+	for _, c := range cuts[1:] {
+		if last := &merged[len(merged)-1]; c[0] <= last[1] {
+			if c[1] > last[1] {
+				last[1] = c[1]
+			}
+		} else {
+			merged = append(merged, c)
+		}
+	}
+	for i := len(merged) - 1; i >= 0; i-- {
+		bin = splice(bin, merged[i][0], merged[i][1], "")
+	}
+	return bin, true
+}
+
+// scanTOML finds lines of keys and table headers.
+func scanTOML(bin []byte) (list []tomlLine, ok bool) {
+	table := ""
+	count := map[string]int{}
+
+	for i := 0; i < len(bin); {
+		head := i
+		for i < len(bin) && (bin[i] == ' ' || bin[i] == '\t') {
+			i++
+		}
+
+		switch {
+		case i >= len(bin):
+
+		case bin[i] == '\r' || bin[i] == '\n' || bin[i] == '#':
+			i = lineEnd(bin, i) + 1
+
+		case bin[i] == '[':
+			array := i+1 < len(bin) && bin[i+1] == '['
+			if array {
+				i++
+			}
+
+			keys, j, ok := tomlKeys(bin, i+1)
+			if !ok || j >= len(bin) || bin[j] != ']' || array && (j+1 >= len(bin) || bin[j+1] != ']') {
+				return nil, false
+			}
+
+			// parents may be arrays of tables
+			path, full := "", strings.Join(keys, ".")
+			for _, k := range keys {
+				path = join(path, k)
+				if n, ok := count[path]; ok && path != full {
+					path = join(path, strconv.Itoa(n-1))
+				}
+			}
+			if array {
+				count[path]++
+				path = join(path, strconv.Itoa(count[path]-1))
+			}
+
+			table = path
+			i = lineEnd(bin, i) + 1
+			list = append(list, tomlLine{path: path, table: path, header: true, head: head, start: j, end: j, next: i})
+
+		default:
+			keys, j, ok := tomlKeys(bin, i)
+			if !ok || j >= len(bin) || bin[j] != '=' {
+				return nil, false
+			}
+
+			start := j + 1
+			for start < len(bin) && (bin[start] == ' ' || bin[start] == '\t') {
+				start++
+			}
+			end := tomlValueEnd(bin, start)
+			if end < 0 {
+				return nil, false
+			}
+
+			path := table
+			for _, k := range keys {
+				path = join(path, k)
+			}
+			i = lineEnd(bin, end) + 1
+			list = append(list, tomlLine{path: path, table: table, head: head, start: start, end: end, next: i})
+		}
+	}
+
+	for i := range list {
+		if list[i].next > len(bin) {
+			list[i].next = len(bin)
+		}
+	}
+	return list, true
+}
+
+// tomlKeys parses a dotted key starting at i. It returns the keys and the
+// offset of the next byte after spaces.
+func tomlKeys(bin []byte, i int) (keys []string, next int, ok bool) {
+	space := func() {
+		for i < len(bin) && (bin[i] == ' ' || bin[i] == '\t') {
+			i++
+		}
+	}
+
+	for {
+		space()
+		if i >= len(bin) {
+			return nil, i, false
+		}
+
+		switch bin[i] {
+		case '"', '\'':
+			end := tomlValueEnd(bin, i)
+			if end < 0 {
+				return nil, i, false
+			}
+			key := string(bin[i+1 : end-1])
+			if bin[i] == '"' {
+				if k, err := strconv.Unquote(string(bin[i:end])); err == nil {
+					key = k
+				}
+			}
+			keys, i = append(keys, key), end
+		default:
+			j := i
+			for j < len(bin) && isBareKey(bin[j]) {
+				j++
+			}
+			if j == i {
+				return nil, i, false
+			}
+			keys, i = append(keys, string(bin[i:j])), j
+		}
+
+		space()
+		if i >= len(bin) || bin[i] != '.' {
+			return keys, i, true
+		}
+		i++
+	}
+}
+
+func isBareKey(b byte) bool {
+	return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9' || b == '_' || b == '-'
+}
+
+// tomlValueEnd returns the end of the value starting at i, or -1.
+func tomlValueEnd(bin []byte, i int) int {
+	if i >= len(bin) {
+		return -1
+	}
+
+	rest := bin[i:]
+	switch {
+	case bytes.HasPrefix(rest, []byte(`"""`)), bytes.HasPrefix(rest, []byte(`'''`)):
+		q := rest[:3]
+		for j := i + 3; j+3 <= len(bin); j++ {
+			if bin[j] == '\\' && q[0] == '"' {
+				j++
+				continue
+			}
+			if bytes.HasPrefix(bin[j:], q) {
+				// up to two more quotes are content
+				end := j + 3
+				for n := 0; n < 2 && end < len(bin) && bin[end] == q[0]; n++ {
+					end++
+				}
+				return end
+			}
+		}
+		return -1
+
+	case rest[0] == '"' || rest[0] == '\'':
+		for j := i + 1; j < len(bin) && bin[j] != '\n'; j++ {
+			switch {
+			case bin[j] == '\\' && rest[0] == '"':
+				j++
+			case bin[j] == rest[0]:
+				return j + 1
+			}
+		}
+		return -1
+
+	case rest[0] == '[' || rest[0] == '{':
+		depth := 0
+		for j := i; j < len(bin); j++ {
+			switch bin[j] {
+			case '[', '{':
+				depth++
+			case ']', '}':
+				if depth--; depth == 0 {
+					return j + 1
+				}
+			case '"', '\'':
+				end := tomlValueEnd(bin, j)
+				if end < 0 {
+					return -1
+				}
+				j = end - 1
+			case '#':
+				j = lineEnd(bin, j) - 1
+			}
+		}
+		return -1
+
+	default:
+		j := i
+		for j < len(bin) && !strings.ContainsRune(" \t\r\n#,]}", rune(bin[j])) {
+			j++
+		}
+		// dates may have a space before the time
+		if m := tomlTime.Find(bin[j:]); m != nil && j > i && bytes.Count(bin[i:j], []byte("-")) == 2 {
+			j += len(m)
+		}
+		if j == i {
+			return -1
+		}
+		return j
+	}
+}
+
+var tomlTime = regexp.MustCompile(`^ \d{2}:\d{2}:\d{2}[^\s#,\]}]*`)
+
+// tomlLines encodes val at keys into lines of "key = value". Maps are
+// flattened into dotted keys.
+func tomlLines(keys []string, val interface{}, out *[]string) bool {
+	if m, ok := val.(map[string]interface{}); ok && len(m) != 0 {
+		for _, k := range sortedKeys(m) {
+			if !tomlLines(append(keys[:len(keys):len(keys)], k), m[k], out) {
+				return false
+			}
+		}
+		return true
+	}
+
+	text, ok := tomlInline(val)
+	if ok {
+		names := make([]string, len(keys))
+		for i, k := range keys {
+			names[i] = tomlKeyText(k)
+		}
+		*out = append(*out, strings.Join(names, ".")+" = "+text)
+	}
+	return ok
+}
+
+// tomlInline encodes val in one line, with inline tables for maps.
+func tomlInline(val interface{}) (string, bool) {
+	switch v := val.(type) {
+	case nil:
+		return "", false
+
+	case map[string]interface{}:
+		list := []string{}
+		for _, k := range sortedKeys(v) {
+			text, ok := tomlInline(v[k])
+			if !ok {
+				return "", false
+			}
+			list = append(list, tomlKeyText(k)+" = "+text)
+		}
+		if len(list) == 0 {
+			return "{}", true
+		}
+		return "{ " + strings.Join(list, ", ") + " }", true
+
+	case []interface{}:
+		list := make([]string, len(v))
+		for i, e := range v {
+			text, ok := tomlInline(e)
+			if !ok {
+				return "", false
+			}
+			list[i] = text
+		}
+		return "[" + strings.Join(list, ", ") + "]", true
+
+	default:
+		buf := bytes.Buffer{}
+		if toml.NewEncoder(&buf).Encode(map[string]interface{}{"v": v}) != nil {
+			return "", false
+		}
+		text := strings.TrimSuffix(buf.String(), "\n")
+		if !strings.HasPrefix(text, "v = ") || strings.Contains(text, "\n") {
+			return "", false
+		}
+		return strings.TrimPrefix(text, "v = "), true
+	}
+}
+
+// tomlKeyText quotes k if it is not a bare key.
+func tomlKeyText(k string) string {
+	for i := 0; i < len(k); i++ {
+		if !isBareKey(k[i]) {
+			return strconv.Quote(k)
+		}
+	}
+	if k == "" {
+		return `""`
+	}
+	return k
+}
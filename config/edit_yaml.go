@@ -0,0 +1,423 @@
+package config
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlText edits YAML in place. It keeps comments, blank lines and styles
+// of untouched values. Values inside flow collections are not edited in
+// place.
+type yamlText struct{}
+
+func (yamlText) replace(bin []byte, path []string, val interface{}) ([]byte, bool) {
+	d := parseYAML(bin)
+	if d == nil || len(path) == 0 {
+		return nil, false
+	}
+
+	e, ok := d.find(path)
+	end := d.end(e)
+	if !ok || end < 0 {
+		return nil, false
+	}
+
+	unit := indentUnit(bin, "    ")
+	inline, ok := yamlInline(val)
+
+	if e.key != nil {
+		from := d.colon(e.key)
+		if from < 0 || from > end {
+			return nil, false
+		}
+		if ok {
+			return splice(bin, from, end, " "+inline), true
+		}
+
+		indent := strings.Repeat(" ", e.key.Column-1) + unit
+		lines, ok := yamlBlock(val, unit)
+		if !ok {
+			return nil, false
+		}
+		return splice(bin, from, end, "\n"+indent+strings.Join(lines, "\n"+indent)), true
+	}
+
+	// an item of a sequence
+	from := d.offset(e.val)
+	if from < 0 || isNull(e.val) {
+		return nil, false
+	}
+	if ok {
+		return splice(bin, from, end, inline), true
+	}
+
+	indent := strings.Repeat(" ", e.val.Column-1)
+	lines, ok := yamlBlock(val, unit)
+	if !ok {
+		return nil, false
+	}
+	return splice(bin, from, end, strings.Join(lines, "\n"+indent)), true
+}
+
+func (yamlText) insert(bin []byte, path []string, key string, val interface{}) ([]byte, bool) {
+	d := parseYAML(bin)
+	if d == nil {
+		return nil, false
+	}
+
+	e, ok := d.find(path)
+	m := e.val
+	if !ok || m.Style&yaml.FlowStyle != 0 {
+		return nil, false
+	}
+	if m.Kind == yaml.SequenceNode {
+		return d.append(e, key, val)
+	}
+	if m.Kind != yaml.MappingNode || len(m.Content) < 2 {
+		return nil, false
+	}
+
+	last := yamlEntry{key: m.Content[len(m.Content)-2], val: m.Content[len(m.Content)-1], limit: e.limit}
+	end := d.end(last)
+	name, ok := yamlInline(key)
+	if end < 0 || !ok {
+		return nil, false
+	}
+
+	unit := indentUnit(bin, "    ")
+	indent := strings.Repeat(" ", m.Content[0].Column-1)
+	text := "\n" + indent + name + ":"
+	if inline, ok := yamlInline(val); ok {
+		text += " " + inline
+	} else if lines, ok := yamlBlock(val, unit); ok {
+		text += "\n" + indent + unit + strings.Join(lines, "\n"+indent+unit)
+	} else {
+		return nil, false
+	}
+
+	at := lineEnd(bin, end)
+	return splice(bin, at, at, text), true
+}
+
+// append val after the last item of the sequence of e, if key is its
+// length.
+func (d *yamlDoc) append(e yamlEntry, key string, val interface{}) ([]byte, bool) {
+	l, bin := e.val, d.bin
+	if len(l.Content) == 0 || key != strconv.Itoa(len(l.Content)) {
+		return nil, false
+	}
+
+	last := yamlEntry{val: l.Content[len(l.Content)-1], limit: e.limit}
+	start, end := d.offset(last.val), d.end(last)
+	if start < 0 || end < 0 || isNull(last.val) {
+		return nil, false
+	}
+
+	// items start with "-" at the same column
+	head := lineStart(bin, start)
+	if string(bytes.TrimSpace(bin[head:start])) != "-" {
+		return nil, false
+	}
+	dash := head + bytes.IndexByte(bin[head:start], '-')
+
+	unit := indentUnit(bin, "    ")
+	indent := string(bin[head:dash])
+	inner := indent + strings.Repeat(" ", start-dash)
+	text := "\n" + indent + "-" + strings.Repeat(" ", start-dash-1)
+	if inline, ok := yamlInline(val); ok {
+		text += inline
+	} else if lines, ok := yamlBlock(val, unit); ok {
+		text += strings.Join(lines, "\n"+inner)
+	} else {
+		return nil, false
+	}
+
+	at := lineEnd(bin, end)
+	return splice(bin, at, at, text), true
+}
+
+func (yamlText) remove(bin []byte, path []string) ([]byte, bool) {
+	d := parseYAML(bin)
+	if d == nil || len(path) == 0 {
+		return nil, false
+	}
+
+	e, ok := d.find(path)
+	end := d.end(e)
+	if !ok || end < 0 {
+		return nil, false
+	}
+
+	// only remove whole lines
+	first, mark := e.key, ""
+	if first == nil {
+		first, mark = e.val, "-"
+	}
+	start := d.offset(first)
+	if start < 0 || isNull(first) {
+		return nil, false
+	}
+	head := lineStart(bin, start)
+	if string(bytes.TrimSpace(bin[head:start])) != mark {
+		return nil, false
+	}
+
+	stop := lineEnd(bin, end)
+	if stop < len(bin) {
+		stop++
+	}
+	return splice(bin, head, stop, ""), true
+}
+
+// yamlDoc is a YAML document with offsets of its lines.
+type yamlDoc struct {
+	bin   []byte
+	lines []int
+	root  *yaml.Node
+}
+
+// yamlEntry is a value found by a path, and its key if it is in a map.
+type yamlEntry struct {
+	key   *yaml.Node
+	val   *yaml.Node
+	limit int // where the next entry begins
+}
+
+func parseYAML(bin []byte) *yamlDoc {
+	node := yaml.Node{}
+	if yaml.Unmarshal(bin, &node) != nil || node.Kind != yaml.DocumentNode || len(node.Content) == 0 {
+		return nil
+	}
+
+	d := &yamlDoc{bin: bin, lines: []int{0}, root: node.Content[0]}
+	for i, b := range bin {
+		if b == '\n' {
+			d.lines = append(d.lines, i+1)
+		}
+	}
+	return d
+}
+
+// offset returns the offset of n, or -1 if unknown.
+func (d *yamlDoc) offset(n *yaml.Node) int {
+	if n.Line < 1 || n.Line > len(d.lines) {
+		return -1
+	}
+
+	// columns count runes
+	i := d.lines[n.Line-1]
+	for c := 1; c < n.Column && i < len(d.bin) && d.bin[i] != '\n'; c++ {
+		_, size := utf8.DecodeRune(d.bin[i:])
+		i += size
+	}
+	return i
+}
+
+// find the entry at path. All maps and sequences on the path should be in
+// the block style.
+func (d *yamlDoc) find(path []string) (e yamlEntry, ok bool) {
+	e = yamlEntry{val: d.root, limit: len(d.bin)}
+	for _, k := range path {
+		n, next := e.val, -1
+		if n.Style&yaml.FlowStyle != 0 {
+			return e, false
+		}
+
+		switch n.Kind {
+		case yaml.MappingNode:
+			i := -1
+			for j := 0; j+1 < len(n.Content); j += 2 {
+				if n.Content[j].Value == k {
+					i = j // the last one wins
+				}
+			}
+			if i < 0 {
+				return e, false
+			}
+			e.key, e.val = n.Content[i], n.Content[i+1]
+			if i+2 < len(n.Content) {
+				next = i + 2
+			}
+
+		case yaml.SequenceNode:
+			i, ok := index(k, len(n.Content))
+			if !ok {
+				return e, false
+			}
+			e.key, e.val = nil, n.Content[i]
+			if i+1 < len(n.Content) {
+				next = i + 1
+			}
+
+		default:
+			return e, false
+		}
+
+		if next >= 0 {
+			at := d.offset(n.Content[next])
+			if at < 0 {
+				return e, false
+			}
+			e.limit = lineStart(d.bin, at)
+		}
+	}
+	return e, true
+}
+
+// end returns the end of the value of e, or -1 if unknown.
+func (d *yamlDoc) end(e yamlEntry) int {
+	n := e.val
+	switch start := d.offset(n); {
+	case n == nil:
+		return -1
+	case e.key != nil && isNull(n):
+		return d.colon(e.key)
+	case start < 0:
+		return -1
+	case n.Kind == yaml.ScalarNode && n.Style&(yaml.LiteralStyle|yaml.FoldedStyle) == 0:
+		return d.scalarEnd(start, n.Style)
+	case n.Style&yaml.FlowStyle != 0:
+		return flowEnd(d.bin, start)
+	default:
+		// the last line with content before the next entry
+		end := start
+		for i := start; i < e.limit; {
+			j := lineEnd(d.bin, i)
+			if line := bytes.TrimSpace(d.bin[i:j]); len(line) != 0 && line[0] != '#' {
+				end = i + len(bytes.TrimRight(d.bin[i:j], " \t\r"))
+			}
+			i = j + 1
+		}
+		return end
+	}
+}
+
+// colon returns the offset after the ":" following key, or -1.
+func (d *yamlDoc) colon(key *yaml.Node) int {
+	i := d.offset(key)
+	if i < 0 {
+		return -1
+	}
+	if i = d.scalarEnd(i, key.Style); i < 0 {
+		return -1
+	}
+	for i < len(d.bin) && (d.bin[i] == ' ' || d.bin[i] == '\t') {
+		i++
+	}
+	if i < len(d.bin) && d.bin[i] == ':' {
+		return i + 1
+	}
+	return -1
+}
+
+// scalarEnd returns the end of a scalar in one line starting at i.
+func (d *yamlDoc) scalarEnd(i int, style yaml.Style) int {
+	bin := d.bin
+	switch {
+	case style&yaml.DoubleQuotedStyle != 0:
+		if i >= len(bin) || bin[i] != '"' {
+			return -1
+		}
+		for i++; i < len(bin); i++ {
+			switch bin[i] {
+			case '\\':
+				i++
+			case '"':
+				return i + 1
+			}
+		}
+		return -1
+
+	case style&yaml.SingleQuotedStyle != 0:
+		if i >= len(bin) || bin[i] != '\'' {
+			return -1
+		}
+		for i++; i < len(bin); i++ {
+			if bin[i] == '\'' {
+				if i+1 < len(bin) && bin[i+1] == '\'' {
+					i++
+					continue
+				}
+				return i + 1
+			}
+		}
+		return -1
+
+	default:
+		// until a comment, a ": " or the end of line
+		end := lineEnd(bin, i)
+		for j := i; j < end; j++ {
+			space := j+1 == end || bin[j+1] == ' ' || bin[j+1] == '\t'
+			if bin[j] == ':' && space || bin[j] == '#' && j > i && (bin[j-1] == ' ' || bin[j-1] == '\t') {
+				end = j
+				break
+			}
+		}
+		return i + len(bytes.TrimRight(bin[i:end], " \t\r"))
+	}
+}
+
+// flowEnd returns the end of a flow collection starting at i, or -1.
+func flowEnd(bin []byte, i int) int {
+	depth := 0
+	for ; i < len(bin); i++ {
+		switch bin[i] {
+		case '[', '{':
+			depth++
+		case ']', '}':
+			if depth--; depth == 0 {
+				return i + 1
+			}
+		case '"', '\'':
+			q := bin[i]
+			for i++; i < len(bin) && bin[i] != q; i++ {
+				if q == '"' && bin[i] == '\\' {
+					i++
+				}
+			}
+		}
+	}
+	return -1
+}
+
+func isNull(n *yaml.Node) bool {
+	return n.Kind == yaml.ScalarNode && n.Tag == "!!null" && n.Value == ""
+}
+
+// yamlInline encodes val in one line. It returns false if val should be
+// in the block style.
+func yamlInline(val interface{}) (string, bool) {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		return "{}", len(v) == 0
+	case []interface{}:
+		return "[]", len(v) == 0
+	}
+
+	n := yaml.Node{}
+	if n.Encode(val) != nil || n.Kind != yaml.ScalarNode {
+		return "", false
+	}
+	if strings.Contains(n.Value, "\n") {
+		n.Style = yaml.DoubleQuotedStyle
+	}
+
+	bin, err := yaml.Marshal(&n)
+	text := strings.TrimSuffix(string(bin), "\n")
+	return text, err == nil && !strings.Contains(text, "\n")
+}
+
+// yamlBlock encodes val in lines without the leading indentation.
+func yamlBlock(val interface{}, unit string) ([]string, bool) {
+	buf := bytes.Buffer{}
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(len(unit))
+	if enc.Encode(val) != nil || enc.Close() != nil {
+		return nil, false
+	}
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n"), true
+}
@@ -2,9 +2,11 @@ package config
 
 import (
 	"io"
+	"io/fs"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 
 	"github.com/wiryls/pkg/errors/cerrors"
@@ -12,69 +14,177 @@ import (
 )
 
 // Save writes the configuration to target path.
-func (s Marshalers) Save(src interface{}, dst string) (err error) {
+//  - It writes to a temporary file and then renames it to dst, so dst is
+//    never left half-written.
+//  - The permissions of an existing dst are preserved. New files use the
+//    mode from `OptionMode`, or 0644 by default.
+//  - With `OptionKeepFormat`, an existing dst is edited in place.
+func (s Marshalers) Save(src interface{}, dst string, opts ...Option) (err error) {
 	err = cerrors.TestNilArgumentIfNoErr(err, src, "src interface{}")
+
+	o := collect(opts)
+	if err == nil && o.keep {
+		if e, fail := s.Edit(dst); fail == nil {
+			err = e.update(src, o)
+			if err == nil {
+				err = e.Save(opts...)
+			}
+			return
+		}
+	}
+
 	if err == nil {
-		err = s.toFile(dst, src)
+		err = s.toFile(dst, dst, src, o)
 	}
 	return
 }
 
 // Load a configuration form target path.
-func (s Marshalers) Load(dst interface{}, src string) (err error) {
+func (s Marshalers) Load(dst interface{}, src string, opts ...Option) (err error) {
 	err = cerrors.TestNilArgumentIfNoErr(err, dst, "dst interface{}")
+	o := collect(opts)
 	if err == nil {
-		err = s.fromFile(src, dst)
+		err = s.fromFile(src, dst, o)
+	}
+	if err == nil {
+		err = o.overlay(dst)
 	}
 	return
 }
 
 // LoadSome reads a list of files and finds the first valid one as configuration.
-func (s Marshalers) LoadSome(dst interface{}, list []string) (path string, err error) {
+func (s Marshalers) LoadSome(dst interface{}, list []string, opts ...Option) (path string, err error) {
 
 	err = cerrors.TestNilArgumentIfNoErr(err, list, "list []string")
 	err = cerrors.TestNilArgumentIfNoErr(err, dst, "dst interface{}")
 
+	o := collect(opts)
 	if err == nil {
-		msg := strings.Builder{}
-		for _, p := range list {
-			if err := s.fromFile(p, dst); err == nil {
-				path = p
-				break
-			} else {
-				if msg.Len() > 0 {
-					msg.WriteString("; ")
-				}
-				msg.WriteString(err.Error())
-			}
+		var i int
+		i, err = some(len(list), func(i int) error { return s.fromFile(list[i], dst, o) })
+		if err == nil {
+			path = list[i]
 		}
+	}
 
-		switch {
-		case path != "":
-			err = nil
-		case msg.Len() == 0:
-			err = wrap.Message(ErrReading, "no files")
-		default:
-			err = wrap.Message(ErrReading, msg.String())
+	if err == nil {
+		err = o.overlay(dst)
+	}
+
+	return
+}
+
+// LoadReader reads a configuration from r. The format is a tag or an
+// extension like "json" or ".yaml".
+func (s Marshalers) LoadReader(dst interface{}, r io.Reader, format string, opts ...Option) (err error) {
+	err = cerrors.TestNilArgumentIfNoErr(err, dst, "dst interface{}")
+	o := collect(opts)
+	if err == nil {
+		err = s.fromReader(nameOf(format), r, dst, o)
+	}
+	if err == nil {
+		err = o.overlay(dst)
+	}
+	return
+}
+
+// SaveWriter writes the configuration to w. The format is a tag or an
+// extension like "json" or ".yaml".
+func (s Marshalers) SaveWriter(src interface{}, w io.Writer, format string, opts ...Option) (err error) {
+	err = cerrors.TestNilArgumentIfNoErr(err, src, "src interface{}")
+	if err == nil {
+		err = s.toWriter(nameOf(format), w, src, collect(opts))
+	}
+	return
+}
+
+// LoadFS is the `fs.FS` version of `Load`.
+func (s Marshalers) LoadFS(dst interface{}, fsys fs.FS, src string, opts ...Option) (err error) {
+	err = cerrors.TestNilArgumentIfNoErr(err, dst, "dst interface{}")
+	err = cerrors.TestNilArgumentIfNoErr(err, fsys, "fsys fs.FS")
+	o := collect(opts)
+	if err == nil {
+		err = s.fromFS(fsys, src, dst, o)
+	}
+	if err == nil {
+		err = o.overlay(dst)
+	}
+	return
+}
+
+// LoadSomeFS is the `fs.FS` version of `LoadSome`.
+func (s Marshalers) LoadSomeFS(dst interface{}, fsys fs.FS, list []string, opts ...Option) (path string, err error) {
+
+	err = cerrors.TestNilArgumentIfNoErr(err, list, "list []string")
+	err = cerrors.TestNilArgumentIfNoErr(err, dst, "dst interface{}")
+	err = cerrors.TestNilArgumentIfNoErr(err, fsys, "fsys fs.FS")
+
+	o := collect(opts)
+	if err == nil {
+		var i int
+		i, err = some(len(list), func(i int) error { return s.fromFS(fsys, list[i], dst, o) })
+		if err == nil {
+			path = list[i]
+		}
+	}
+
+	if err == nil {
+		err = o.overlay(dst)
+	}
+
+	return
+}
+
+// some tries to load each of n items and returns the index of the first
+// loaded one.
+func some(n int, load func(int) error) (index int, err error) {
+	msg := strings.Builder{}
+	for index = 0; index < n; index++ {
+		if err := load(index); err == nil {
+			break
+		} else {
+			if msg.Len() > 0 {
+				msg.WriteString("; ")
+			}
+			msg.WriteString(err.Error())
 		}
 	}
 
+	switch {
+	case index < n:
+		err = nil
+	case msg.Len() == 0:
+		err = wrap.Message(ErrReading, "no files")
+	default:
+		err = wrap.Message(ErrReading, msg.String())
+	}
+
 	return
 }
 
+// nameOf creates a dummy file name for a format, so that it could be
+// dispatched by its extension.
+func nameOf(format string) string {
+	return "." + strings.TrimPrefix(format, ".")
+}
+
 // LoadOrSaveSome trys to read a config from some paths.
-// If failed, save dst to the first valid path.
-func (s Marshalers) LoadOrSaveSome(data interface{}, list []string) (path string, find bool, err error) {
+// If failed, fill zero fields of data by `Defaults` and save it to the
+// first valid path.
+func (s Marshalers) LoadOrSaveSome(data interface{}, list []string, opts ...Option) (path string, find bool, err error) {
 
 	if err == nil {
-		path, err = s.LoadSome(data, list)
+		path, err = s.LoadSome(data, list, opts...)
 		find = err == nil
 	}
 
-	if err != nil {
+	if err != nil && path == "" {
+		if err := Defaults(data); err != nil {
+			return "", false, err
+		}
 		for _, path := range list {
-			if err := s.Save(data, path); err == nil {
-				return path, false, nil
+			if err := s.Save(data, path, opts...); err == nil {
+				return path, false, collect(opts).overlay(data)
 			}
 		}
 	}
@@ -82,8 +192,8 @@ func (s Marshalers) LoadOrSaveSome(data interface{}, list []string) (path string
 	return
 }
 
-func (s Marshalers) fromFile(path string, target interface{}) (err error) {
-	var buf io.ReadCloser
+func (s Marshalers) fromFile(path string, target interface{}, o *options) (err error) {
+	var bin []byte
 
 	if err == nil {
 		_, err = os.Stat(path)
@@ -91,35 +201,141 @@ func (s Marshalers) fromFile(path string, target interface{}) (err error) {
 	}
 
 	if err == nil {
-		buf, err = os.Open(path)
+		bin, err = ioutil.ReadFile(path)
+		err = wrap.MessageAliasStack(err, "cannot read `"+path+"`", ErrReading, 0)
+	}
+
+	switch {
+	case err != nil:
+	case mayInclude(bin) || o.migrations.has() && mayMigrate(target):
+		var tree map[string]interface{}
+		var tag string
+		var done bool
+		tree, tag, done, err = s.fromFileTree(path, bin, o)
+		switch {
+		case err != nil:
+		case done:
+			err = s.fromTree(nameOf(tag), tree, target, o)
+		default:
+			err = s.fromBytes(path, bin, target, o)
+		}
+	default:
+		err = s.fromBytes(path, bin, target, o)
+	}
+
+	return
+}
+
+// fromFileTree decodes a file into a generic tree with its includes merged
+// and migrations applied. It also returns the tag of its format, and whether
+// the tree is changed from the file.
+func (s Marshalers) fromFileTree(path string, bin []byte, o *options) (tree map[string]interface{}, tag string, done bool, err error) {
+	var included bool
+	if err == nil {
+		tree, included, err = s.include(path, bin, nil, o)
+		tag = s.format(path, bin)
+	}
+
+	if err == nil {
+		done, err = o.migrations.migrate(path, tree)
+	}
+
+	if err == nil && done && o.migrated && !included {
+		e := s.editor(path, tag, bin)
+		err = e.update(tree, o)
+		if err == nil {
+			err = e.save(o)
+		}
+	}
+
+	done = done || included
+
+	return
+}
+
+func (s Marshalers) fromFS(fsys fs.FS, path string, target interface{}, o *options) (err error) {
+	var buf fs.File
+
+	if err == nil {
+		buf, err = fsys.Open(path)
 		err = wrap.MessageAliasStack(err, "cannot open `"+path+"`", ErrReading, 0)
 	}
 
 	if err == nil {
 		defer buf.Close()
-		err = s.fromReader(path, buf, target)
+		err = s.fromReader(path, buf, target, o)
 	}
 
 	return
 }
 
-func (s Marshalers) toFile(path string, src interface{}) (err error) {
-	var buf io.WriteCloser
+// toFile writes src to path atomically in the format of name.
+func (s Marshalers) toFile(path, name string, src interface{}, o *options) error {
+	return writeFile(path, o, func(w io.Writer) error {
+		return s.toWriter(name, w, src, o)
+	})
+}
+
+// writeFile replaces path atomically with what write writes.
+func writeFile(path string, o *options, write func(w io.Writer) error) (err error) {
+	var tmp *os.File
+	var mode = o.mode
 
 	if err == nil {
-		buf, err = os.Create(path)
+		if real, e := filepath.EvalSymlinks(path); e == nil {
+			path = real
+		}
+		if info, e := os.Stat(path); e == nil {
+			mode = info.Mode().Perm()
+		}
+
+		tmp, err = ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*")
 		err = wrap.MessageAliasStack(err, "cannot create `"+path+"`", ErrWriting, 0)
 	}
 
 	if err == nil {
-		defer buf.Close()
-		err = s.toWriter(path, buf, src)
+		defer func() {
+			if err != nil {
+				tmp.Close()
+				os.Remove(tmp.Name())
+			}
+		}()
+
+		err = write(tmp)
+	}
+
+	if err == nil {
+		err = tmp.Chmod(mode)
+		err = wrap.MessageAliasStack(err, "cannot chmod `"+tmp.Name()+"`", ErrWriting, 0)
+	}
+
+	if err == nil {
+		err = tmp.Sync()
+		err = wrap.MessageAliasStack(err, "cannot sync `"+tmp.Name()+"`", ErrWriting, 0)
+	}
+
+	if err == nil {
+		err = tmp.Close()
+		err = wrap.MessageAliasStack(err, "cannot close `"+tmp.Name()+"`", ErrWriting, 0)
+	}
+
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+		err = wrap.MessageAliasStack(err, "cannot replace `"+path+"`", ErrWriting, 0)
+	}
+
+	if err == nil {
+		// best effort to persist the rename, may fail on some platforms
+		if dir, e := os.Open(filepath.Dir(path)); e == nil {
+			dir.Sync()
+			dir.Close()
+		}
 	}
 
 	return
 }
 
-func (s Marshalers) fromReader(name string, r io.Reader, dst interface{}) (err error) {
+func (s Marshalers) fromReader(name string, r io.Reader, dst interface{}, o *options) (err error) {
 
 	err = cerrors.TestNilArgumentIfNoErr(err, r, "r io.Reader")
 	err = cerrors.TestNilArgumentIfNoErr(err, dst, "dst interface{}")
@@ -130,17 +346,21 @@ func (s Marshalers) fromReader(name string, r io.Reader, dst interface{}) (err e
 		err = wrap.MessageAliasStack(err, "cannot read `"+name+"`", ErrReading, 0)
 	}
 
-	return s.fromBytes(name, bin, dst)
+	if err == nil {
+		err = s.fromBytes(name, bin, dst, o)
+	}
+
+	return
 }
 
-func (s Marshalers) toWriter(name string, w io.Writer, src interface{}) (err error) {
+func (s Marshalers) toWriter(name string, w io.Writer, src interface{}, o *options) (err error) {
 
 	err = cerrors.TestNilArgumentIfNoErr(err, w, "w io.Writer")
 	err = cerrors.TestNilArgumentIfNoErr(err, src, "src interface{}")
 
 	var bin []byte
 	if err == nil {
-		bin, err = s.toBytes(name, src)
+		bin, err = s.toBytes(name, src, o)
 	}
 
 	if err == nil {
@@ -151,19 +371,26 @@ func (s Marshalers) toWriter(name string, w io.Writer, src interface{}) (err err
 	return
 }
 
-func (s Marshalers) fromBytes(name string, bin []byte, dst interface{}) (err error) {
+func (s Marshalers) fromBytes(name string, bin []byte, dst interface{}, o *options) (err error) {
 
 	err = cerrors.TestNilArgumentIfNoErr(err, bin, "bin []byte")
 	err = cerrors.TestNilArgumentIfNoErr(err, dst, "dst interface{}")
 
 	if err == nil {
-		ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
-		msg := "failed to encode " + ext
-
-		for _, s := range s {
-			if s.CanUnmarshal(ext, bin) {
-				err = s.Unmarshal(bin, dst)
-				err = wrap.MessageAliasStack(err, msg, ErrDecoding, 0)
+		tag := o.tag
+		if tag == "" {
+			tag = s.format(name, bin)
+		}
+		for _, m := range s {
+			if m.CanUnmarshal(tag, bin) {
+				err = m.Unmarshal(bin, dst)
+				err = decodeError(name, tag, bin, err, o.blind)
+				if err == nil && o.strict {
+					err = s.strict(name, tag, bin, dst, !o.blind)
+				}
+				if err == nil {
+					err = o.decoded(dst)
+				}
 				return
 			}
 		}
@@ -172,17 +399,39 @@ func (s Marshalers) fromBytes(name string, bin []byte, dst interface{}) (err err
 	return wrap.MessageStack(ErrDecoding, "file not support `"+name+"`", 0)
 }
 
-func (s Marshalers) toBytes(name string, src interface{}) (bin []byte, err error) {
+// format finds the tag to unmarshal bin. It tries the extension of name
+// first, then sniffs the content. Return "" if none is supported.
+func (s Marshalers) format(name string, bin []byte) string {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
+	for _, tag := range []string{ext, sniff(bin)} {
+		for _, s := range s {
+			if s.CanUnmarshal(tag, bin) {
+				return tag
+			}
+		}
+	}
+	return ""
+}
+
+func (s Marshalers) toBytes(name string, src interface{}, o *options) (bin []byte, err error) {
 
 	err = cerrors.TestNilArgumentIfNoErr(err, src, "src interface{}")
+	if err == nil {
+		src, err = o.encoding(src)
+	}
 
 	if err == nil {
 		ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
 		msg := "failed to encode " + ext
+		comments := descriptions(reflect.TypeOf(src))
 
 		for _, s := range s {
 			if s.CanMarshal(ext, src) {
-				bin, err = s.Marshal(src)
+				if c, ok := s.(CommentMarshaler); ok && len(comments) != 0 {
+					bin, err = c.MarshalComment(src, comments)
+				} else {
+					bin, err = s.Marshal(src)
+				}
 				err = wrap.MessageAliasStack(err, msg, ErrEncoding, 0)
 				return
 			}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/wiryls/pkg/errors/cerrors"
+	"github.com/wiryls/pkg/errors/wrap"
+)
+
+// fromEnv overrides the fields of dst with environment variables.
+func fromEnv(dst interface{}, prefix string) (err error) {
+	v := reflect.ValueOf(dst)
+	err = cerrors.TestInvalidArgument(
+		v.Kind() != reflect.Ptr || v.IsNil(),
+		"dst interface{}", "a non-nil pointer is required")
+
+	if err == nil {
+		e := envs{prefix: prefix}
+		err = e.visit(v.Elem(), nil, "")
+	}
+
+	return
+}
+
+// envs walks through a value and counts what it changes.
+type envs struct {
+	prefix string
+	change int
+	fresh  map[reflect.Type]bool // types of nil pointers being visited
+}
+
+func (e *envs) name(path []string, tag string) string {
+	switch {
+	case tag != "":
+		return tag
+	case e.prefix == "" || len(path) == 0:
+		return ""
+	default:
+		name := strings.Join(append([]string{e.prefix}, path...), "_")
+		name = strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+		return name
+	}
+}
+
+func (e *envs) visit(v reflect.Value, path []string, tag string) (err error) {
+	if isLeaf(v.Type()) {
+		if name := e.name(path, tag); name != "" {
+			if raw, ok := os.LookupEnv(name); ok {
+				err = parseText(v, raw)
+				err = wrap.MessageAliasStack(err, "cannot parse $"+name, ErrParsing, 0)
+				e.change++
+			}
+		}
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.Type().Elem().Kind() == reflect.Struct {
+			if v.IsNil() {
+				// stop at recursive types like `Next *Node`
+				t := v.Type().Elem()
+				if e.fresh[t] {
+					return
+				}
+				if e.fresh == nil {
+					e.fresh = map[reflect.Type]bool{}
+				}
+				e.fresh[t] = true
+				defer delete(e.fresh, t)
+
+				n := reflect.New(t)
+				c := e.change
+				if err = e.visit(n.Elem(), path, tag); err == nil && c != e.change {
+					v.Set(n)
+				}
+			} else {
+				err = e.visit(v.Elem(), path, tag)
+			}
+		}
+
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; err == nil && i < t.NumField(); i++ {
+			f := t.Field(i)
+			switch {
+			case isInline(f):
+				err = e.visit(v.Field(i), path, "")
+			case fieldName(f) != "":
+				err = e.visit(v.Field(i), append(path[:len(path):len(path)], fieldName(f)), f.Tag.Get("env"))
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; err == nil && i < v.Len(); i++ {
+			err = e.visit(v.Index(i), append(path[:len(path):len(path)], strconv.Itoa(i)), "")
+		}
+	}
+
+	return
+}
@@ -0,0 +1,98 @@
+package config_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/wiryls/pkg/config"
+)
+
+func TestEnv(t *testing.T) {
+	assert := assert.New(t)
+
+	type DB struct {
+		Host    string        `json:"host"`
+		Port    int           `json:"port"`
+		Timeout time.Duration `json:"timeout"`
+	}
+	type Data struct {
+		Name   string   `json:"name" env:"TEST_CONFIG_NAME"`
+		Debug  bool     `json:"debug"`
+		Ratio  float64  `json:"ratio"`
+		Tags   []string `json:"tags"`
+		DB     DB       `json:"db"`
+		Backup *DB      `json:"backup"`
+		Peers  []DB     `json:"peers"`
+	}
+
+	tmp, err := ioutil.TempFile("", "*.json")
+	assert.NoError(err)
+
+	dst := tmp.Name()
+	tmp.Close()
+	defer os.Remove(dst)
+
+	in := Data{Name: "file", DB: DB{Host: "localhost", Port: 5432}, Peers: []DB{{}}}
+	assert.NoError(config.Save(&in, dst))
+
+	env := map[string]string{
+		"TEST_CONFIG_NAME":  "env",
+		"TEST_DEBUG":        "true",
+		"TEST_RATIO":        "0.25",
+		"TEST_TAGS":         "a, b",
+		"TEST_DB_PORT":      "6543",
+		"TEST_DB_TIMEOUT":   "3s",
+		"TEST_BACKUP_HOST":  "example.com",
+		"TEST_PEERS_0_HOST": "peer",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	{ // overlay
+		out := Data{}
+		assert.NoError(config.Load(&out, dst, config.OptionEnv("TEST")))
+		assert.Equal("env", out.Name)
+		assert.True(out.Debug)
+		assert.Equal(0.25, out.Ratio)
+		assert.Equal([]string{"a", "b"}, out.Tags)
+		assert.Equal("localhost", out.DB.Host)
+		assert.Equal(6543, out.DB.Port)
+		assert.Equal(3*time.Second, out.DB.Timeout)
+		assert.Equal(&DB{Host: "example.com"}, out.Backup)
+		assert.Equal("peer", out.Peers[0].Host)
+	}
+	{ // tags only
+		out := Data{}
+		assert.NoError(config.Load(&out, dst, config.OptionEnv("")))
+		assert.Equal("env", out.Name)
+		assert.Equal(5432, out.DB.Port)
+		assert.Nil(out.Backup)
+	}
+	{ // parsing error
+		os.Setenv("TEST_DB_PORT", "orz")
+		out := Data{}
+		_, err := config.LoadSome(&out, []string{dst}, config.OptionEnv("TEST"))
+		assert.Error(err)
+		assert.True(errors.Is(err, config.ErrParsing))
+	}
+	{ // recursive types
+		type Node struct {
+			Name string `json:"name"`
+			Next *Node  `json:"next"`
+		}
+
+		os.Setenv("TEST_NEXT_NAME", "next")
+		defer os.Unsetenv("TEST_NEXT_NAME")
+
+		out := Node{}
+		assert.NoError(config.Load(&out, dst, config.OptionEnv("TEST")))
+		assert.Equal(&Node{Name: "next"}, out.Next)
+	}
+}
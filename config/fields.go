@@ -0,0 +1,318 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	typeDuration        = reflect.TypeOf(time.Duration(0))
+	typeTextUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// fieldName returns the name of a struct field used in field paths. The
+// name comes from the first `json`, `yaml` or `toml` tag, or the lower-cased
+// field name. An empty name means the field should be skipped.
+func fieldName(f reflect.StructField) string {
+	if f.PkgPath != "" {
+		return "" // unexported
+	}
+
+	for _, key := range []string{"json", "yaml", "toml"} {
+		if tag, ok := f.Tag.Lookup(key); ok {
+			name := strings.Split(tag, ",")[0]
+			switch {
+			case name == "-":
+				return ""
+			case name != "":
+				return name
+			}
+		}
+	}
+
+	return strings.ToLower(f.Name)
+}
+
+// isInline tests if a struct field is an embedded struct that should be
+// flattened into its parent.
+func isInline(f reflect.StructField) bool {
+	if !f.Anonymous {
+		return false
+	}
+
+	for _, key := range []string{"json", "yaml", "toml"} {
+		if tag := f.Tag.Get(key); strings.Split(tag, ",")[0] != "" {
+			return false
+		}
+	}
+
+	t := f.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct
+}
+
+// isLeaf tests if values of type t could be parsed from a single string.
+func isLeaf(t reflect.Type) bool {
+	if reflect.PtrTo(t).Implements(typeTextUnmarshaler) {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return isLeaf(t.Elem())
+	case reflect.Slice:
+		return t.Elem().Kind() != reflect.Slice && isLeaf(t.Elem())
+	case reflect.String,
+		reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseText parses raw and stores the result in v.
+//  - Slices are separated by ",".
+//  - Durations use the format of `time.ParseDuration`.
+func parseText(v reflect.Value, raw string) (err error) {
+	if v.CanAddr() && v.Addr().Type().Implements(typeTextUnmarshaler) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(raw))
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		n := reflect.New(v.Type().Elem())
+		if err = parseText(n.Elem(), raw); err == nil {
+			v.Set(n)
+		}
+
+	case reflect.String:
+		v.SetString(raw)
+
+	case reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(raw); err == nil {
+			v.SetBool(b)
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		if v.Type() == typeDuration {
+			var d time.Duration
+			d, err = time.ParseDuration(raw)
+			i = int64(d)
+		} else {
+			i, err = strconv.ParseInt(raw, 0, v.Type().Bits())
+		}
+		if err == nil {
+			v.SetInt(i)
+		}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		if u, err = strconv.ParseUint(raw, 0, v.Type().Bits()); err == nil {
+			v.SetUint(u)
+		}
+
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if f, err = strconv.ParseFloat(raw, v.Type().Bits()); err == nil {
+			v.SetFloat(f)
+		}
+
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes([]byte(raw))
+			break
+		}
+
+		var list []string
+		if strings.TrimSpace(raw) != "" {
+			list = strings.Split(raw, ",")
+		}
+
+		n := reflect.MakeSlice(v.Type(), len(list), len(list))
+		for i := 0; err == nil && i < len(list); i++ {
+			err = parseText(n.Index(i), strings.TrimSpace(list[i]))
+		}
+		if err == nil {
+			v.Set(n)
+		}
+
+	default:
+		err = fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return
+}
+
+// leaves calls fn with the path of each leaf field in struct type t.
+//  - Slices of structs and maps are not leaves and will be skipped.
+func leaves(t reflect.Type, path []string, fn func(path []string, f reflect.StructField)) {
+	seen := map[reflect.Type]bool{}
+
+	var visit func(t reflect.Type, path []string)
+	visit = func(t reflect.Type, path []string) {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct || seen[t] {
+			return
+		}
+
+		seen[t] = true
+		defer delete(seen, t)
+
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			switch name := fieldName(f); {
+			case isInline(f):
+				visit(f.Type, path)
+			case name == "":
+			case isLeaf(f.Type):
+				fn(append(path[:len(path):len(path)], name), f)
+			default:
+				visit(f.Type, append(path[:len(path):len(path)], name))
+			}
+		}
+	}
+
+	visit(t, path)
+}
+
+// lookup finds the value of a field path in v.
+//  - If alloc is true, nil pointers on the path will be allocated.
+func lookup(v reflect.Value, path []string, alloc bool) (reflect.Value, bool) {
+	for {
+		if !v.IsValid() {
+			return v, false
+		}
+
+		if v.Kind() == reflect.Ptr && len(path) != 0 {
+			if v.IsNil() {
+				if !alloc || !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+			continue
+		}
+
+		if len(path) == 0 {
+			return v, true
+		}
+
+		switch v.Kind() {
+		case reflect.Struct:
+			v = structField(v, path[0])
+		case reflect.Slice, reflect.Array:
+			i, err := strconv.Atoi(path[0])
+			if err != nil || i < 0 || i >= v.Len() {
+				return reflect.Value{}, false
+			}
+			v = v.Index(i)
+		default:
+			return reflect.Value{}, false
+		}
+		path = path[1:]
+	}
+}
+
+// structField finds a field by its name in struct v and its inline structs.
+func structField(v reflect.Value, name string) reflect.Value {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		switch {
+		case isInline(f):
+			in := v.Field(i)
+			if in.Kind() == reflect.Ptr {
+				if in.IsNil() {
+					continue
+				}
+				in = in.Elem()
+			}
+			if out := structField(in, name); out.IsValid() {
+				return out
+			}
+		case fieldName(f) == name:
+			return v.Field(i)
+		}
+	}
+	return reflect.Value{}
+}
+
+// clone deep-copies exported fields of v. Unexported fields are copied
+// shallowly.
+func clone(v reflect.Value) reflect.Value {
+	if !v.IsValid() {
+		return v
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		n := reflect.New(v.Type().Elem())
+		n.Elem().Set(clone(v.Elem()))
+		return n
+
+	case reflect.Struct:
+		n := reflect.New(v.Type()).Elem()
+		n.Set(v)
+		for i := 0; i < n.NumField(); i++ {
+			if f := n.Field(i); f.CanSet() {
+				f.Set(clone(f))
+			}
+		}
+		return n
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		n := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			n.Index(i).Set(clone(v.Index(i)))
+		}
+		return n
+
+	case reflect.Array:
+		n := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			n.Index(i).Set(clone(v.Index(i)))
+		}
+		return n
+
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		n := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for _, k := range v.MapKeys() {
+			n.SetMapIndex(k, clone(v.MapIndex(k)))
+		}
+		return n
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		n := reflect.New(v.Type()).Elem()
+		n.Set(clone(v.Elem()))
+		return n
+
+	default:
+		return v
+	}
+}
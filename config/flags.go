@@ -0,0 +1,149 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/wiryls/pkg/errors/cerrors"
+	"github.com/wiryls/pkg/errors/wrap"
+)
+
+// BindFlags defines a flag in fs for each leaf field of dst.
+//  - Flag names are field paths like `db.host`, or come from tag
+//    `flag:"name"`. Tag `flag:"-"` skips a field.
+//  - Flag usages come from tag `desc:"..."`.
+//  - Parsing fs writes values to dst directly. Use `OptionFlags(fs)` while
+//    loading to apply them again, so that the precedence is always: defaults,
+//    files, environment variables and then flags.
+func BindFlags(fs *flag.FlagSet, dst interface{}) (err error) {
+	v := reflect.ValueOf(dst)
+	err = cerrors.TestNilArgumentIfNoErr(err, fs, "fs *flag.FlagSet")
+	err = cerrors.TestNilArgumentIfNoErr(err, dst, "dst interface{}")
+	if err == nil {
+		err = cerrors.TestInvalidArgument(
+			v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct,
+			"dst interface{}", "a pointer to struct is required")
+	}
+
+	if err == nil {
+		leaves(v.Type(), nil, func(path []string, f reflect.StructField) {
+			name := strings.Join(path, ".")
+			if tag := f.Tag.Get("flag"); tag == "-" {
+				return
+			} else if tag != "" {
+				name = tag
+			}
+
+			if err == nil && fs.Lookup(name) != nil {
+				err = cerrors.InvalidArgument("fs *flag.FlagSet", "flag redefined: "+name)
+			}
+			if err == nil {
+				field := &fieldFlag{root: v, path: path}
+				if f.Type.Kind() == reflect.Bool {
+					fs.Var(&boolFlag{field}, name, f.Tag.Get("desc"))
+				} else {
+					fs.Var(field, name, f.Tag.Get("desc"))
+				}
+			}
+		})
+	}
+
+	return
+}
+
+// OptionFlags applies flags set in fs after loading. The fs should be bound
+// by `BindFlags` and parsed.
+func OptionFlags(fs *flag.FlagSet) Option {
+	return func(o *options) { o.flags = fs }
+}
+
+// fromFlags applies the flags which have been set in fs to dst.
+func fromFlags(dst interface{}, fs *flag.FlagSet) (err error) {
+	v := reflect.ValueOf(dst)
+	err = cerrors.TestInvalidArgument(
+		v.Kind() != reflect.Ptr || v.IsNil(),
+		"dst interface{}", "a non-nil pointer is required")
+
+	if err == nil {
+		fs.Visit(func(f *flag.Flag) {
+			var field *fieldFlag
+			switch x := f.Value.(type) {
+			case *fieldFlag:
+				field = x
+			case *boolFlag:
+				field = x.fieldFlag
+			}
+
+			if err == nil && field != nil && field.set {
+				err = (&fieldFlag{root: v, path: field.path}).Set(field.raw)
+				err = wrap.MessageAliasStack(err, "cannot parse -"+f.Name, ErrParsing, 0)
+			}
+		})
+	}
+
+	return
+}
+
+// fieldFlag is a `flag.Value` bound to a field of a struct.
+type fieldFlag struct {
+	root reflect.Value
+	path []string
+	raw  string
+	set  bool
+}
+
+func (f *fieldFlag) String() string {
+	if f == nil {
+		return ""
+	}
+
+	v, ok := lookup(f.root, f.path, false)
+	if !ok {
+		return ""
+	}
+
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+		list := make([]string, v.Len())
+		for i := range list {
+			list[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		return strings.Join(list, ",")
+	}
+
+	return fmt.Sprint(v.Interface())
+}
+
+func (f *fieldFlag) Set(raw string) (err error) {
+	v, ok := lookup(f.root, f.path, true)
+	if !ok {
+		err = fmt.Errorf("cannot find field %s", strings.Join(f.path, "."))
+	}
+	if err == nil {
+		err = parseText(v, raw)
+	}
+	if err == nil {
+		f.raw, f.set = raw, true
+	}
+	return
+}
+
+// boolFlag is a fieldFlag which could be set without a value, like `-debug`.
+type boolFlag struct{ *fieldFlag }
+
+func (f *boolFlag) IsBoolFlag() bool { return true }
+
+func (f *boolFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.fieldFlag.String()
+}
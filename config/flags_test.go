@@ -0,0 +1,83 @@
+package config_test
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/wiryls/pkg/config"
+)
+
+func TestBindFlags(t *testing.T) {
+	assert := assert.New(t)
+
+	type DB struct {
+		Host    string        `json:"host"`
+		Port    int           `json:"port" desc:"port of database"`
+		Timeout time.Duration `json:"timeout"`
+	}
+	type Data struct {
+		Name  string   `json:"name" flag:"n"`
+		Debug bool     `json:"debug"`
+		Tags  []string `json:"tags"`
+		Skip  string   `json:"skip" flag:"-"`
+		DB    DB       `json:"db"`
+		Peer  *DB      `json:"peer"`
+	}
+
+	tmp, err := ioutil.TempFile("", "*.json")
+	assert.NoError(err)
+
+	dst := tmp.Name()
+	tmp.Close()
+	defer os.Remove(dst)
+
+	assert.NoError(config.Save(&Data{Name: "file", DB: DB{Host: "file", Port: 1}}, dst))
+
+	os.Setenv("TEST_FLAGS_DB_HOST", "env")
+	defer os.Unsetenv("TEST_FLAGS_DB_HOST")
+	os.Setenv("TEST_FLAGS_DB_PORT", "2")
+	defer os.Unsetenv("TEST_FLAGS_DB_PORT")
+
+	{ // precedence: defaults < file < env < flags
+		out := Data{DB: DB{Timeout: time.Second}}
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		assert.NoError(config.BindFlags(fs, &out))
+		assert.Nil(fs.Lookup("skip"))
+		assert.NotNil(fs.Lookup("n"))
+		assert.Equal("port of database", fs.Lookup("db.port").Usage)
+		assert.Equal("1s", fs.Lookup("db.timeout").DefValue)
+
+		err := fs.Parse([]string{"-debug", "-tags", "a,b", "-db.port", "3", "-peer.host", "peer"})
+		assert.NoError(err)
+		assert.Equal(3, out.DB.Port)
+
+		_, err = config.LoadSome(&out, []string{dst},
+			config.OptionFlags(fs),
+			config.OptionEnv("TEST_FLAGS"))
+		assert.NoError(err)
+		assert.Equal("file", out.Name)
+		assert.True(out.Debug)
+		assert.Equal([]string{"a", "b"}, out.Tags)
+		assert.Equal("env", out.DB.Host)
+		assert.Equal(3, out.DB.Port)
+		assert.Equal(time.Duration(0), out.DB.Timeout)
+		assert.Equal(&DB{Host: "peer"}, out.Peer)
+	}
+	{ // help and errors
+		out := Data{}
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(new(bytes.Buffer))
+		assert.NoError(config.BindFlags(fs, &out))
+		fs.PrintDefaults()
+
+		assert.Error(fs.Parse([]string{"-db.port", "orz"}))
+		assert.Error(config.BindFlags(fs, &out))
+		assert.Error(config.BindFlags(fs, out))
+	}
+}
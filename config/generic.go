@@ -0,0 +1,121 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// This file contains helpers for generic trees, which are decoded from
+// configs without knowing their types. A tree only contains
+// `map[string]interface{}`, `[]interface{}` and scalars.
+
+// toTree decodes bin into a generic tree by the extension of name.
+//  - JSON numbers are decoded as `json.Number`, so that big integers are
+//    kept as they are.
+func (s Marshalers) toTree(name string, bin []byte) (tree map[string]interface{}, err error) {
+	if tag := s.format(name, bin); tag == "json" {
+		dec := json.NewDecoder(bytes.NewReader(bin))
+		dec.UseNumber()
+		err = dec.Decode(&tree)
+		if _, e := dec.Token(); err == nil && e != io.EOF {
+			err = errors.New("invalid data after top-level value")
+		}
+		err = decodeError(name, tag, bin, err, false)
+	} else {
+		err = s.fromBytes(name, bin, &tree, collect(nil))
+	}
+	if err == nil {
+		tree, _ = normalize(tree).(map[string]interface{})
+		if tree == nil {
+			tree = map[string]interface{}{}
+		}
+	}
+	return
+}
+
+// fromTree decodes a generic tree into dst by the extension of name. The
+// tree is encoded in that format again so that the tags of dst still work.
+func (s Marshalers) fromTree(name string, tree map[string]interface{}, dst interface{}, o *options) (err error) {
+	var bin []byte
+	if err == nil {
+		bin, err = s.toBytes(name, tree, collect(nil))
+	}
+	if err == nil {
+		// positions in the re-encoded tree are meaningless
+		c := *o
+		c.blind = true
+		err = s.fromBytes(name, bin, dst, &c)
+	}
+	return
+}
+
+// normalize converts maps and slices decoded by different marshalers to
+// `map[string]interface{}` and `[]interface{}`.
+//  - Integers of all kinds become int64, or uint64 if they are too large.
+//  - `json.Number` becomes int64, or float64 if it is not an integer.
+//  - Integral floats become int64, as JSON does not distinguish them.
+func normalize(x interface{}) interface{} {
+	switch v := x.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = normalize(e)
+		}
+		return v
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = normalize(e)
+		}
+		return m
+	case []interface{}:
+		for i, e := range v {
+			v[i] = normalize(e)
+		}
+		return v
+	case []map[string]interface{}:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			l[i] = normalize(e)
+		}
+		return l
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return normalize(f)
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v)
+		}
+		return v
+	}
+
+	switch v := reflect.ValueOf(x); v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Uint() <= math.MaxInt64 {
+			return int64(v.Uint())
+		}
+		return v.Uint()
+	default:
+		return x
+	}
+}
+
+// sortedKeys returns keys of m in order.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/wiryls/pkg/errors/wrap"
+)
+
+// A config file could include other files by a top-level key `$include`,
+// whose value is a path or a list of paths:
+//
+//     $include: ["db.json", "conf.d/*.json"]
+//
+// In TOML, the key must be quoted as `"$include"`.
+//
+//  - Relative paths are resolved from the directory of the including file.
+//  - Glob patterns are expanded in lexical order. A pattern without any
+//    match is ignored.
+//  - Included files are deep-merged over the including file by order, and
+//    slices follow `OptionSlices`.
+//  - Includes could be nested up to `includeDepth` levels, and cycles fail
+//    with an error aliased to `ErrParsing`.
+
+const (
+	includeKey   = "$include"
+	includeDepth = 8
+)
+
+// mayInclude is a quick check before decoding. Whether bin really includes
+// others is known only after decoding.
+func mayInclude(bin []byte) bool {
+	return bytes.Contains(bin, []byte(includeKey))
+}
+
+// include decodes bin of path into a generic tree and merges its includes.
+// It also returns whether there is a top-level `$include`.
+func (s Marshalers) include(path string, bin []byte, stack []string, o *options) (tree map[string]interface{}, found bool, err error) {
+	abs, _ := filepath.Abs(path)
+	for i, p := range stack {
+		if p == abs {
+			cycle := strings.Join(append(stack[i:], abs), " -> ")
+			return nil, false, wrap.MessageStack(ErrParsing, "include cycle "+cycle, 0)
+		}
+	}
+	if len(stack) >= includeDepth {
+		return nil, false, wrap.MessageStack(ErrParsing, "includes are too deep in `"+path+"`", 0)
+	}
+	stack = append(stack[:len(stack):len(stack)], abs)
+
+	if err == nil {
+		tree, err = s.toTree(path, bin)
+	}
+
+	var patterns []string
+	if err == nil {
+		_, found = tree[includeKey]
+		patterns, err = includes(tree[includeKey])
+		err = wrap.MessageAliasStack(err, "invalid "+includeKey+" in `"+path+"`", ErrParsing, 0)
+		delete(tree, includeKey)
+	}
+
+	var list []string
+	for _, pattern := range patterns {
+		if err != nil {
+			break
+		}
+
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(path), pattern)
+		}
+
+		if strings.ContainsAny(pattern, "*?[") {
+			var found []string
+			found, err = filepath.Glob(pattern)
+			err = wrap.MessageAliasStack(err, "invalid pattern `"+pattern+"`", ErrParsing, 0)
+			list = append(list, found...)
+		} else {
+			list = append(list, pattern)
+		}
+	}
+
+	m := merger{policy: o.slices, origins: Origins{}, root: tree}
+	for _, next := range list {
+		if err != nil {
+			break
+		}
+
+		var sub map[string]interface{}
+		bin, err = ioutil.ReadFile(next)
+		err = wrap.MessageAliasStack(err, "cannot read `"+next+"`", ErrReading, 0)
+
+		if err == nil {
+			sub, _, err = s.include(next, bin, stack, o)
+		}
+		if err == nil {
+			m.root = m.merge(m.root, sub, nil)
+		}
+	}
+
+	if err == nil {
+		tree, _ = m.root.(map[string]interface{})
+	}
+	return
+}
+
+// includes converts the value of `$include` to a list of paths.
+func includes(x interface{}) (list []string, err error) {
+	switch v := x.(type) {
+	case nil:
+	case string:
+		list = []string{v}
+	case []interface{}:
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				list = append(list, s)
+			} else {
+				err = errors.New("paths must be strings")
+			}
+		}
+	default:
+		err = errors.New("must be a path or a list of paths")
+	}
+	return
+}
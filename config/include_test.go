@@ -0,0 +1,79 @@
+package config_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/wiryls/pkg/config"
+)
+
+func TestInclude(t *testing.T) {
+	assert := assert.New(t)
+
+	type DB struct {
+		Host string `json:"host" yaml:"host" toml:"host"`
+		Port int    `json:"port" yaml:"port" toml:"port"`
+	}
+	type Data struct {
+		Name    string   `json:"name"    yaml:"name"    toml:"name"`
+		DB      DB       `json:"db"      yaml:"db"      toml:"db"`
+		Plugins []string `json:"plugins" yaml:"plugins" toml:"plugins"`
+	}
+
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	assert.NoError(os.Mkdir(filepath.Join(dir, "conf.d"), 0755))
+
+	for name, text := range map[string]string{
+		"main.yaml":      "$include: [db.json, conf.d/*.toml, empty.d/*.json]\nname: main\nplugins: [a]\n",
+		"db.json":        `{"db": {"host": "localhost", "port": 5432}}`,
+		"conf.d/10.toml": "plugins = [\"b\"]\n[db]\nport = 6543\n",
+		"conf.d/20.toml": "\"$include\" = \"../extra.json\"\nplugins = [\"c\"]\n",
+		"extra.json":     `{"name": "extra"}`,
+		"cycle/a.json":   `{"$include": "b.json"}`,
+		"cycle/b.json":   `{"$include": ["a.json"]}`,
+		"invalid.json":   `{"$include": 1}`,
+		"missing.json":   `{"$include": "nothing.json"}`,
+		"text.json":      "{\n  \"name\": \"see $include\",\n  \"db\": {\"port\": \"x\"}\n}",
+	} {
+		path := filepath.Join(dir, name)
+		assert.NoError(os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(ioutil.WriteFile(path, []byte(text), 0644))
+	}
+
+	{ // merge
+		out := Data{}
+		assert.NoError(config.Load(&out, filepath.Join(dir, "main.yaml"),
+			config.OptionSlices(config.SliceAppend)))
+		assert.Equal(Data{
+			Name:    "extra",
+			DB:      DB{Host: "localhost", Port: 6543},
+			Plugins: []string{"a", "b", "c"},
+		}, out)
+	}
+	{ // errors
+		out := Data{}
+		err := config.Load(&out, filepath.Join(dir, "cycle", "a.json"))
+		assert.True(errors.Is(err, config.ErrParsing))
+		assert.Contains(err.Error(), "include cycle")
+
+		err = config.Load(&out, filepath.Join(dir, "invalid.json"))
+		assert.True(errors.Is(err, config.ErrParsing))
+
+		err = config.Load(&out, filepath.Join(dir, "missing.json"))
+		assert.True(errors.Is(err, config.ErrReading))
+	}
+	{ // not a real include, decoded as it is
+		out := Data{}
+		err := config.Load(&out, filepath.Join(dir, "text.json"))
+		e := &config.DecodeError{}
+		assert.True(errors.As(err, &e))
+		assert.Equal(3, e.Line)
+	}
+}
@@ -0,0 +1,211 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/wiryls/pkg/errors/wrap"
+)
+
+// OptionInterpolate expands references in string values after decoding.
+//  - `${NAME}` is replaced by the environment variable `NAME`.
+//  - `${NAME:-default}` uses default if `NAME` is unset or empty.
+//  - `${.field.path}` is replaced by the value of another field.
+//  - `$${` is an escaped `${`.
+//
+// Unresolved or cyclic references fail with an error aliased to
+// `ErrParsing`.
+func OptionInterpolate() Option {
+	return func(o *options) { o.interpolate = true }
+}
+
+// interpolate expands all string fields of dst in place.
+func interpolate(dst interface{}) (err error) {
+	root := reflect.ValueOf(dst)
+	x := expander{root: root, slots: map[string]*slot{}}
+	x.collect(root, nil)
+
+	for i := 0; err == nil && i < len(x.order); i++ {
+		_, err = x.resolve(x.order[i], nil)
+	}
+
+	for i := 0; err == nil && i < len(x.order); i++ {
+		if s := x.slots[x.order[i]]; s.text != s.get() {
+			s.set(s.text)
+		}
+	}
+
+	return
+}
+
+// slot is a string value that could be read and written.
+type slot struct {
+	get  func() string
+	set  func(string)
+	text string
+	done bool
+	busy bool
+}
+
+// expander expands references among slots.
+type expander struct {
+	root  reflect.Value
+	slots map[string]*slot
+	order []string
+}
+
+func (x *expander) add(path []string, s *slot) {
+	key := strings.Join(path, ".")
+	x.slots[key] = s
+	x.order = append(x.order, key)
+}
+
+func (x *expander) collect(v reflect.Value, path []string) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		if v.CanSet() {
+			x.add(path, &slot{get: v.String, set: v.SetString})
+		}
+
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			switch name := fieldName(f); {
+			case isInline(f):
+				x.collect(v.Field(i), path)
+			case name != "":
+				x.collect(v.Field(i), append(path[:len(path):len(path)], name))
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			x.collect(v.Index(i), append(path[:len(path):len(path)], strconv.Itoa(i)))
+		}
+
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			next := append(path[:len(path):len(path)], fmt.Sprint(k.Interface()))
+			if e := v.MapIndex(k); e.Kind() == reflect.String {
+				m, k, t := v, k, e.Type()
+				x.add(next, &slot{
+					get: func() string { return m.MapIndex(k).String() },
+					set: func(s string) { m.SetMapIndex(k, reflect.ValueOf(s).Convert(t)) },
+				})
+			} else {
+				x.collect(e, next)
+			}
+		}
+	}
+}
+
+// resolve expands the slot of key. The stack is used to find cycles.
+func (x *expander) resolve(key string, stack []string) (text string, err error) {
+	s := x.slots[key]
+	switch {
+	case s.done:
+		return s.text, nil
+	case s.busy:
+		cycle := append(stack, key)
+		err = errors.New("cyclic reference " + strings.Join(cycle, " -> "))
+		return
+	}
+
+	s.busy = true
+	text, err = x.expand(s.get(), append(stack, key))
+	s.busy = false
+
+	if err == nil {
+		s.text, s.done = text, true
+	} else if len(stack) == 0 {
+		err = wrap.MessageAliasStack(err, "cannot expand `"+key+"`", ErrParsing, 0)
+	}
+	return
+}
+
+// expand all references in text.
+func (x *expander) expand(text string, stack []string) (string, error) {
+	if !strings.Contains(text, "${") {
+		return text, nil
+	}
+
+	out := strings.Builder{}
+	for {
+		i := strings.Index(text, "${")
+		if i < 0 {
+			out.WriteString(text)
+			return out.String(), nil
+		}
+
+		if i > 0 && text[i-1] == '$' {
+			out.WriteString(text[:i])
+			out.WriteString("{")
+			text = text[i+2:]
+			continue
+		}
+
+		j, depth := i+2, 1
+		for ; j < len(text) && depth > 0; j++ {
+			switch text[j] {
+			case '{':
+				depth++
+			case '}':
+				depth--
+			}
+		}
+		if depth != 0 {
+			return "", errors.New("unclosed reference in `" + text + "`")
+		}
+
+		value, err := x.reference(text[i+2:j-1], stack)
+		if err != nil {
+			return "", err
+		}
+
+		out.WriteString(text[:i])
+		out.WriteString(value)
+		text = text[j:]
+	}
+}
+
+// reference resolves the content of `${...}`.
+func (x *expander) reference(ref string, stack []string) (string, error) {
+	if strings.HasPrefix(ref, ".") {
+		key := strings.TrimPrefix(ref, ".")
+		if _, ok := x.slots[key]; ok {
+			return x.resolve(key, stack)
+		}
+		if v, ok := lookup(x.root, strings.Split(key, "."), false); ok {
+			for v.Kind() == reflect.Ptr && !v.IsNil() {
+				v = v.Elem()
+			}
+			return fmt.Sprint(v.Interface()), nil
+		}
+		return "", errors.New("undefined field " + ref)
+	}
+
+	name, def, fallback := ref, "", false
+	if i := strings.Index(ref, ":-"); i >= 0 {
+		name, def, fallback = ref[:i], ref[i+2:], true
+	}
+
+	if value, ok := os.LookupEnv(name); ok && (value != "" || !fallback) {
+		return value, nil
+	}
+	if fallback {
+		return x.expand(def, stack)
+	}
+	return "", errors.New("undefined variable $" + name)
+}
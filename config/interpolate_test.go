@@ -0,0 +1,80 @@
+package config_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/wiryls/pkg/config"
+)
+
+func TestInterpolate(t *testing.T) {
+	assert := assert.New(t)
+
+	type Data struct {
+		Host   string            `json:"host"`
+		Port   int               `json:"port"`
+		URL    string            `json:"url"`
+		User   string            `json:"user"`
+		Home   string            `json:"home"`
+		Text   string            `json:"text"`
+		Tags   []string          `json:"tags"`
+		Labels map[string]string `json:"labels"`
+	}
+
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	os.Setenv("TEST_CONFIG_USER", "orz")
+	defer os.Unsetenv("TEST_CONFIG_USER")
+	os.Unsetenv("TEST_CONFIG_HOME")
+
+	load := func(text string, opts ...config.Option) (Data, error) {
+		dst := filepath.Join(dir, "config.json")
+		assert.NoError(ioutil.WriteFile(dst, []byte(text), 0644))
+
+		out := Data{}
+		err := config.Load(&out, dst, opts...)
+		return out, err
+	}
+
+	{ // expand
+		out, err := load(`{
+			"host": "localhost",
+			"port": 80,
+			"url": "http://${.host}:${.port}/${.user}",
+			"user": "${TEST_CONFIG_USER}",
+			"home": "${TEST_CONFIG_HOME:-/home/${.user}}",
+			"text": "$${TEST_CONFIG_USER} \"quoted\"",
+			"tags": ["${.labels.k}"],
+			"labels": {"k": "${.host}"}
+		}`, config.OptionInterpolate())
+		assert.NoError(err)
+		assert.Equal("http://localhost:80/orz", out.URL)
+		assert.Equal("orz", out.User)
+		assert.Equal("/home/orz", out.Home)
+		assert.Equal(`${TEST_CONFIG_USER} "quoted"`, out.Text)
+		assert.Equal([]string{"localhost"}, out.Tags)
+		assert.Equal("localhost", out.Labels["k"])
+	}
+	{ // disabled
+		out, err := load(`{"user": "${TEST_CONFIG_USER}"}`)
+		assert.NoError(err)
+		assert.Equal("${TEST_CONFIG_USER}", out.User)
+	}
+	{ // unresolved
+		_, err := load(`{"user": "${TEST_CONFIG_HOME}"}`, config.OptionInterpolate())
+		assert.True(errors.Is(err, config.ErrParsing))
+		assert.Contains(err.Error(), "`user`")
+	}
+	{ // cyclic
+		_, err := load(`{"host": "${.url}", "url": "${.host}"}`, config.OptionInterpolate())
+		assert.True(errors.Is(err, config.ErrParsing))
+		assert.Contains(err.Error(), "host -> url -> host")
+	}
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/wiryls/pkg/errors/cerrors"
+	"github.com/wiryls/pkg/errors/wrap"
+)
+
+// SlicePolicy decides how `LoadLayers` merges two slices.
+type SlicePolicy int
+
+// Slice policies.
+const (
+	// SliceReplace replaces the previous slice with the latter one.
+	SliceReplace SlicePolicy = iota
+	// SliceAppend appends the latter slice to the previous one.
+	SliceAppend
+	// SliceMerge merges two slices element by element.
+	SliceMerge
+)
+
+// OptionSlices sets the policy of merging slices in `LoadLayers`. The
+// default one is `SliceReplace`.
+func OptionSlices(policy SlicePolicy) Option {
+	return func(o *options) { o.slices = policy }
+}
+
+// Origins maps field paths, like `db.host` or `servers.0.port`, to the
+// files where their values come from.
+type Origins map[string]string
+
+// LoadLayers loads files in list by order and deep-merges each one over the
+// previous, then decodes the result into dst.
+//  - Files that do not exist are skipped.
+//  - Maps are merged by keys and slices by `OptionSlices`.
+//  - The returned `Origins` records which file each field comes from.
+func (s Marshalers) LoadLayers(dst interface{}, list []string, opts ...Option) (origins Origins, err error) {
+
+	err = cerrors.TestNilArgumentIfNoErr(err, list, "list []string")
+	err = cerrors.TestNilArgumentIfNoErr(err, dst, "dst interface{}")
+
+	o := collect(opts)
+	m := merger{policy: o.slices, origins: Origins{}}
+	last := ""
+
+	for i := 0; err == nil && i < len(list); i++ {
+		path := list[i]
+		if _, e := os.Stat(path); os.IsNotExist(e) {
+			continue
+		}
+
+		var bin []byte
+		bin, err = ioutil.ReadFile(path)
+		err = wrap.MessageAliasStack(err, "cannot read `"+path+"`", ErrReading, 0)
+
+		var tree map[string]interface{}
+		if err == nil {
+			tree, last, _, err = s.fromFileTree(path, bin, o)
+		}
+		if err == nil {
+			m.file = path
+			m.root = m.merge(m.root, tree, nil)
+		}
+	}
+
+	if err == nil && last == "" {
+		err = wrap.Message(ErrReading, "no files")
+	}
+
+	if err == nil {
+		root, _ := m.root.(map[string]interface{})
+		err = s.fromTree(nameOf(last), root, dst, o)
+	}
+
+	if err == nil {
+		origins = m.origins
+		err = o.overlay(dst)
+	}
+
+	return
+}
+
+// merger deep-merges generic trees.
+type merger struct {
+	policy  SlicePolicy
+	origins Origins
+	file    string
+	root    interface{}
+}
+
+func (m *merger) merge(dst, src interface{}, path []string) interface{} {
+	switch s := src.(type) {
+	case map[string]interface{}:
+		if d, ok := dst.(map[string]interface{}); ok {
+			for k, v := range s {
+				d[k] = m.merge(d[k], v, append(path[:len(path):len(path)], k))
+			}
+			return d
+		}
+
+	case []interface{}:
+		if d, ok := dst.([]interface{}); ok {
+			switch m.policy {
+			case SliceAppend:
+				for i, v := range s {
+					m.record(v, append(path[:len(path):len(path)], strconv.Itoa(len(d)+i)))
+				}
+				return append(d, s...)
+
+			case SliceMerge:
+				for i, v := range s {
+					next := append(path[:len(path):len(path)], strconv.Itoa(i))
+					if i < len(d) {
+						d[i] = m.merge(d[i], v, next)
+					} else {
+						m.record(v, next)
+						d = append(d, v)
+					}
+				}
+				return d
+			}
+		}
+	}
+
+	m.forget(path)
+	m.record(src, path)
+	return src
+}
+
+// record the origins of x and its children.
+func (m *merger) record(x interface{}, path []string) {
+	switch v := x.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			m.record(e, append(path[:len(path):len(path)], k))
+		}
+	case []interface{}:
+		for i, e := range v {
+			m.record(e, append(path[:len(path):len(path)], strconv.Itoa(i)))
+		}
+	default:
+		if len(path) != 0 {
+			m.origins[strings.Join(path, ".")] = m.file
+		}
+	}
+}
+
+// forget the origins of path and its children.
+func (m *merger) forget(path []string) {
+	if len(path) == 0 {
+		return
+	}
+
+	key := strings.Join(path, ".")
+	for k := range m.origins {
+		if k == key || strings.HasPrefix(k, key+".") {
+			delete(m.origins, k)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/wiryls/pkg/config"
+)
+
+func TestLoadLayers(t *testing.T) {
+	assert := assert.New(t)
+
+	type DB struct {
+		Host string `json:"host" yaml:"host" toml:"host"`
+		Port int    `json:"port" yaml:"port" toml:"port"`
+	}
+	type Data struct {
+		Name   string            `json:"name"   yaml:"name"   toml:"name"`
+		DB     DB                `json:"db"     yaml:"db"     toml:"db"`
+		Tags   []string          `json:"tags"   yaml:"tags"   toml:"tags"`
+		Labels map[string]string `json:"labels" yaml:"labels" toml:"labels"`
+	}
+
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"base.yaml": "name: base\ndb:\n  host: localhost\n  port: 5432\ntags: [a]\nlabels:\n  x: base\n",
+		"user.toml": "tags = [\"b\"]\n[db]\nport = 6543\n[labels]\ny = \"user\"\n",
+		"last.json": `{"name": "last", "labels": {"x": "last"}}`,
+	}
+	list := []string{}
+	for _, name := range []string{"base.yaml", "missing.json", "user.toml", "last.json"} {
+		path := filepath.Join(dir, name)
+		list = append(list, path)
+		if text, ok := files[name]; ok {
+			assert.NoError(ioutil.WriteFile(path, []byte(text), 0644))
+		}
+	}
+
+	{ // replace slices
+		out := Data{}
+		origins, err := config.LoadLayers(&out, list)
+		assert.NoError(err)
+		assert.Equal(Data{
+			Name:   "last",
+			DB:     DB{Host: "localhost", Port: 6543},
+			Tags:   []string{"b"},
+			Labels: map[string]string{"x": "last", "y": "user"},
+		}, out)
+
+		assert.Equal(list[3], origins["name"])
+		assert.Equal(list[0], origins["db.host"])
+		assert.Equal(list[2], origins["db.port"])
+		assert.Equal(list[2], origins["tags.0"])
+		assert.Equal(list[3], origins["labels.x"])
+		assert.Equal(list[2], origins["labels.y"])
+		assert.Len(origins, 6)
+	}
+	{ // append slices
+		out := Data{}
+		origins, err := config.LoadLayers(&out, list, config.OptionSlices(config.SliceAppend))
+		assert.NoError(err)
+		assert.Equal([]string{"a", "b"}, out.Tags)
+		assert.Equal(list[0], origins["tags.0"])
+		assert.Equal(list[2], origins["tags.1"])
+	}
+	{ // no files
+		out := Data{}
+		_, err := config.LoadLayers(&out, list[1:2])
+		assert.Error(err)
+	}
+	{ // big integers
+		type Big struct {
+			ID   int64 `json:"id"   yaml:"id"`
+			Size int64 `json:"size" yaml:"size"`
+		}
+		a, b := filepath.Join(dir, "a.json"), filepath.Join(dir, "b.yaml")
+		assert.NoError(ioutil.WriteFile(a, []byte(`{"id": 9007199254740993, "size": 1}`), 0644))
+		assert.NoError(ioutil.WriteFile(b, []byte("size: 9007199254740995\n"), 0644))
+
+		out := Big{}
+		_, err := config.LoadLayers(&out, []string{a, b})
+		assert.NoError(err)
+		assert.Equal(Big{9007199254740993, 9007199254740995}, out)
+	}
+}
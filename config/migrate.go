@@ -0,0 +1,137 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/wiryls/pkg/errors/wrap"
+)
+
+// MigrationFunc upgrades a generic config tree in place.
+type MigrationFunc func(tree map[string]interface{}) error
+
+// Migrations upgrade config files step by step before they are decoded,
+// according to their top-level `version` key.
+//  - Files without `version` are at version 0. Files with a `version` that
+//    is not an integer are decoded as they are, and so are files decoded
+//    into neither structs nor maps.
+//  - Migrations registered by `RegisterMigration` are applied by default.
+//    Use `OptionMigrations` to apply others instead.
+//  - The zero value is ready to use.
+type Migrations struct {
+	lock  sync.RWMutex
+	steps map[int]migration
+}
+
+// migrations is the default one of `RegisterMigration`.
+var migrations = &Migrations{}
+
+// Register a migration from version `from` to `to`.
+//  - It panics if `from` is not less than `to`, fn is nil, or a migration
+//    from `from` has been registered.
+func (m *Migrations) Register(from, to int, fn MigrationFunc) {
+	if from >= to {
+		panic("config: migration must upgrade to a greater version")
+	}
+	if fn == nil {
+		panic("config: migration is nil")
+	}
+
+	defer m.lock.Unlock()
+	/*_*/ m.lock.Lock()
+
+	if _, ok := m.steps[from]; ok {
+		panic("config: migration from version " + strconv.Itoa(from) + " registered twice")
+	}
+	if m.steps == nil {
+		m.steps = map[int]migration{}
+	}
+	m.steps[from] = migration{to, fn}
+}
+
+// OptionMigrations applies migrations of m instead of those registered by
+// `RegisterMigration` while loading files. A nil m applies none.
+func OptionMigrations(m *Migrations) Option {
+	return func(o *options) { o.migrations = m }
+}
+
+// OptionSaveMigrated writes upgraded files back atomically. The files are
+// edited in place like `Editor` does, so comments and the order of keys are
+// kept if possible. Files with `$include` are never written back.
+func OptionSaveMigrated() Option {
+	return func(o *options) { o.migrated = true }
+}
+
+const versionKey = "version"
+
+type migration struct {
+	to int
+	fn MigrationFunc
+}
+
+// has tells whether m has any migration.
+func (m *Migrations) has() bool {
+	if m == nil {
+		return false
+	}
+
+	defer m.lock.RUnlock()
+	/*_*/ m.lock.RLock()
+	return len(m.steps) != 0
+}
+
+// migrate upgrades tree step by step. It returns whether tree is changed.
+func (m *Migrations) migrate(name string, tree map[string]interface{}) (done bool, err error) {
+	version, ok := versionOf(tree[versionKey])
+	if !ok || !m.has() {
+		return
+	}
+
+	defer m.lock.RUnlock()
+	/*_*/ m.lock.RLock()
+
+	for err == nil {
+		step, ok := m.steps[version]
+		if !ok {
+			break
+		}
+
+		err = step.fn(tree)
+		err = wrap.MessageAliasStack(err, fmt.Sprintf(
+			"cannot migrate `%s` from version %d to %d", name, version, step.to), ErrDecoding, 0)
+
+		if err == nil {
+			version, done = step.to, true
+			tree[versionKey] = int64(version)
+		}
+	}
+
+	return
+}
+
+// mayMigrate tells whether a file decoded into target may have a version.
+func mayMigrate(target interface{}) bool {
+	t := reflect.TypeOf(target)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t != nil && (t.Kind() == reflect.Struct || t.Kind() == reflect.Map)
+}
+
+// versionOf returns the version if x is an integer, or a string of it. A
+// missing one is 0.
+func versionOf(x interface{}) (int, bool) {
+	switch v := x.(type) {
+	case nil:
+		return 0, true
+	case int64:
+		return int(v), true
+	case string:
+		n, err := strconv.Atoi(v)
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
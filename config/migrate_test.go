@@ -0,0 +1,139 @@
+package config_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/wiryls/pkg/config"
+)
+
+func TestMigration(t *testing.T) {
+	assert := assert.New(t)
+
+	type Data struct {
+		Version int    `json:"version" yaml:"version"`
+		Address string `json:"address" yaml:"address"`
+		Level   string `json:"level" yaml:"level"`
+	}
+
+	m := &config.Migrations{}
+	// version 0 -> 100: nothing but the version
+	m.Register(0, 100, func(tree map[string]interface{}) error {
+		return nil
+	})
+	// version 100 -> 101: rename `addr` to `address`
+	m.Register(100, 101, func(tree map[string]interface{}) error {
+		tree["address"] = tree["addr"]
+		delete(tree, "addr")
+		return nil
+	})
+	// version 101 -> 102: set default level
+	m.Register(101, 102, func(tree map[string]interface{}) error {
+		if _, ok := tree["level"]; !ok {
+			tree["level"] = "info"
+		}
+		return nil
+	})
+	// version 110 -> 111: always fail
+	m.Register(110, 111, func(tree map[string]interface{}) error {
+		return errors.New("whoops")
+	})
+
+	assert.Panics(func() { m.Register(101, 102, func(map[string]interface{}) error { return nil }) })
+	assert.Panics(func() { m.Register(3, 2, func(map[string]interface{}) error { return nil }) })
+
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	{ // upgrade
+		dst := filepath.Join(dir, "config.yaml")
+		assert.NoError(ioutil.WriteFile(dst, []byte("version: 100\naddr: localhost\n"), 0644))
+
+		out := Data{}
+		assert.NoError(config.Load(&out, dst, config.OptionMigrations(m)))
+		assert.Equal(Data{102, "localhost", "info"}, out)
+
+		bin, err := ioutil.ReadFile(dst)
+		assert.NoError(err)
+		assert.Equal("version: 100\naddr: localhost\n", string(bin))
+	}
+	{ // missing version is 0
+		dst := filepath.Join(dir, "old.json")
+		assert.NoError(ioutil.WriteFile(dst, []byte(`{"addr": "localhost"}`), 0644))
+
+		out := Data{}
+		assert.NoError(config.Load(&out, dst, config.OptionMigrations(m)))
+		assert.Equal(Data{102, "localhost", "info"}, out)
+	}
+	{ // upgrade and write back in place
+		dst := filepath.Join(dir, "saved.yaml")
+		assert.NoError(ioutil.WriteFile(dst, []byte("# app\nversion: 101 # schema\n\naddress: localhost\nlevel: debug\n"), 0644))
+
+		out := Data{}
+		assert.NoError(config.Load(&out, dst, config.OptionMigrations(m), config.OptionSaveMigrated()))
+		assert.Equal(Data{102, "localhost", "debug"}, out)
+
+		bin, err := ioutil.ReadFile(dst)
+		assert.NoError(err)
+		assert.Equal("# app\nversion: 102 # schema\n\naddress: localhost\nlevel: debug\n", string(bin))
+	}
+	{ // failed
+		dst := filepath.Join(dir, "failed.json")
+		assert.NoError(ioutil.WriteFile(dst, []byte(`{"version": 110}`), 0644))
+
+		out := Data{}
+		err := config.Load(&out, dst, config.OptionMigrations(m))
+		assert.True(errors.Is(err, config.ErrDecoding))
+
+		// not in the default migrations
+		assert.NoError(config.Load(&out, dst))
+	}
+	{ // no integral version, no migration
+		dst := filepath.Join(dir, "semver.json")
+		assert.NoError(ioutil.WriteFile(dst, []byte(`{"version": "1.2.3", "addr": "localhost"}`), 0644))
+
+		raw := map[string]interface{}{}
+		assert.NoError(config.Load(&raw, dst, config.OptionMigrations(m)))
+		assert.Equal("1.2.3", raw["version"])
+		assert.Equal("localhost", raw["addr"])
+	}
+}
+
+func TestRegisterMigration(t *testing.T) {
+	assert := assert.New(t)
+
+	// versions far from those of other tests
+	config.RegisterMigration(900, 901, func(tree map[string]interface{}) error {
+		tree["name"] = "migrated"
+		return nil
+	})
+
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	dst := filepath.Join(dir, "config.json")
+	assert.NoError(ioutil.WriteFile(dst, []byte(`{"version": 900}`), 0644))
+
+	raw := map[string]interface{}{}
+	assert.NoError(config.Load(&raw, dst))
+	assert.Equal("migrated", raw["name"])
+
+	raw = map[string]interface{}{}
+	assert.NoError(config.Load(&raw, dst, config.OptionMigrations(nil)))
+	assert.Nil(raw["name"])
+
+	// not a table, no version
+	list := filepath.Join(dir, "list.json")
+	assert.NoError(ioutil.WriteFile(list, []byte(`["a"]`), 0644))
+
+	out := []string{}
+	assert.NoError(config.Load(&out, list))
+	assert.Equal([]string{"a"}, out)
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"flag"
+	"os"
+)
+
+// Option is used to add optional parameters to the methods of `Marshalers`.
+// By using those options, we could custom the way a config is loaded.
+type Option func(*options)
+
+// options collected from a list of `Option`.
+type options struct {
+	env    bool
+	prefix string
+	flags  *flag.FlagSet
+	mode   os.FileMode
+	slices SlicePolicy
+	keys   KeyProvider
+
+	interpolate bool
+	migrations  *Migrations
+	migrated    bool
+	strict      bool
+	keep        bool
+	blind       bool   // do not report positions
+	tag         string // the format forced by a source
+}
+
+func collect(opts []Option) *options {
+	o := &options{mode: 0644, migrations: migrations}
+	for _, f := range opts {
+		if f != nil {
+			f(o)
+		}
+	}
+	return o
+}
+
+// OptionEnv overrides fields with environment variables after loading.
+//  - A field tagged with `env:"NAME"` is read from `NAME`.
+//  - Other fields are read from `PREFIX_FIELD_PATH` if `prefix` is not
+//    empty, e.g. `APP_DB_HOST` for the field path `db.host`.
+func OptionEnv(prefix string) Option {
+	return func(o *options) { o.env, o.prefix = true, prefix }
+}
+
+// OptionMode sets the permissions of new files created by `Save`. Existing
+// files keep their own permissions.
+func OptionMode(mode os.FileMode) Option {
+	return func(o *options) { o.mode = mode.Perm() }
+}
+
+// decoded applies the steps right after decoding to dst.
+func (o *options) decoded(dst interface{}) (err error) {
+	if err == nil && o.interpolate {
+		err = interpolate(dst)
+	}
+	if err == nil && o.keys != nil {
+		err = unseal(dst, o.keys)
+	}
+	return
+}
+
+// encoding prepares src for encoding. It never modifies src but returns a
+// copy if needed.
+func (o *options) encoding(src interface{}) (out interface{}, err error) {
+	out = src
+	if err == nil && o.keys != nil {
+		out, err = seal(out, o.keys)
+	}
+	return
+}
+
+// overlay applies the post-loading steps to dst by order.
+func (o *options) overlay(dst interface{}) (err error) {
+	if err == nil && o.env {
+		err = fromEnv(dst, o.prefix)
+	}
+	if err == nil && o.flags != nil {
+		err = fromFlags(dst, o.flags)
+	}
+	if err == nil {
+		err = Validate(dst)
+	}
+	return
+}
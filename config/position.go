@@ -0,0 +1,314 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"github.com/wiryls/pkg/errors/detail"
+)
+
+// Position in a file. Both line and column start from 1, and zero means
+// unknown.
+type Position struct {
+	Line   int
+	Column int
+}
+
+// String returns something like "line 3, column 5".
+func (p Position) String() string {
+	switch {
+	case p.Line <= 0:
+		return "unknown position"
+	case p.Column <= 0:
+		return "line " + strconv.Itoa(p.Line)
+	default:
+		return "line " + strconv.Itoa(p.Line) + ", column " + strconv.Itoa(p.Column)
+	}
+}
+
+// positionOf converts a byte offset of bin to a position.
+func positionOf(bin []byte, offset int64) Position {
+	if offset < 0 || offset > int64(len(bin)) {
+		return Position{}
+	}
+
+	head := bin[:offset]
+	line := bytes.Count(head, []byte("\n")) + 1
+	column := len(head) - bytes.LastIndexByte(head, '\n')
+	return Position{line, column}
+}
+
+// locate finds the positions of keys in bin of format tag. Keys are field
+// paths like `servers.0.port`. It is best-effort and returns what it finds.
+func locate(tag string, bin []byte) map[string]Position {
+	out := map[string]Position{}
+	switch tag {
+	case "json":
+		locateJSON(bin, out)
+	case "yaml", "yml":
+		locateYAML(bin, out)
+	case "toml":
+		locateTOML(bin, out)
+	}
+	return out
+}
+
+func locateJSON(bin []byte, out map[string]Position) {
+	type frame struct {
+		path  string
+		array bool
+		index int
+		key   string
+		want  bool // expecting a key
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(bin))
+	stack := []*frame{}
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return
+		}
+
+		// closing
+		if tok == json.Delim('}') || tok == json.Delim(']') {
+			if len(stack) != 0 {
+				stack = stack[:len(stack)-1]
+			}
+			continue
+		}
+
+		// keys
+		var top *frame
+		if len(stack) != 0 {
+			top = stack[len(stack)-1]
+		}
+		if key, ok := tok.(string); ok && top != nil && top.want {
+			end := dec.InputOffset()
+			start := int64(bytes.LastIndexByte(bin[:end-1], '"'))
+			top.key, top.want = key, false
+			out[join(top.path, key)] = positionOf(bin, start)
+			continue
+		}
+
+		// values
+		path := ""
+		switch {
+		case top == nil:
+		case top.array:
+			path = join(top.path, strconv.Itoa(top.index))
+			top.index++
+		default:
+			path = join(top.path, top.key)
+			top.want = true
+		}
+
+		switch tok {
+		case json.Delim('{'):
+			stack = append(stack, &frame{path: path, want: true})
+		case json.Delim('['):
+			stack = append(stack, &frame{path: path, array: true})
+		}
+	}
+}
+
+func locateYAML(bin []byte, out map[string]Position) {
+	node := yaml.Node{}
+	if yaml.Unmarshal(bin, &node) != nil {
+		return
+	}
+
+	var visit func(n *yaml.Node, path string)
+	visit = func(n *yaml.Node, path string) {
+		switch n.Kind {
+		case yaml.DocumentNode:
+			for _, c := range n.Content {
+				visit(c, path)
+			}
+		case yaml.SequenceNode:
+			for i, c := range n.Content {
+				visit(c, join(path, strconv.Itoa(i)))
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				k := n.Content[i]
+				next := join(path, k.Value)
+				out[next] = Position{k.Line, k.Column}
+				visit(n.Content[i+1], next)
+			}
+		case yaml.AliasNode:
+			if n.Alias != nil {
+				visit(n.Alias, path)
+			}
+		}
+	}
+	visit(&node, "")
+}
+
+var (
+	locateTable = regexp.MustCompile(`^(\[\[?)\s*([^\]]+?)\s*\]\]?\s*(#.*)?$`)
+	locateKey   = regexp.MustCompile(`^((?:"[^"]*"|'[^']*'|[\w\-]+)(?:\s*\.\s*(?:"[^"]*"|'[^']*'|[\w\-]+))*)\s*=`)
+)
+
+func locateTOML(bin []byte, out map[string]Position) {
+	split := func(key string) []string {
+		list := []string{}
+		for _, k := range strings.Split(key, ".") {
+			list = append(list, strings.Trim(strings.TrimSpace(k), `"'`))
+		}
+		return list
+	}
+
+	table := ""
+	count := map[string]int{}
+	scan := bufio.NewScanner(bytes.NewReader(bin))
+	for line := 1; scan.Scan(); line++ {
+		text := scan.Text()
+		trim := strings.TrimLeft(text, " \t")
+		column := len(text) - len(trim) + 1
+
+		if m := locateTable.FindStringSubmatch(trim); m != nil {
+			// an array of tables has an index
+			path := ""
+			for _, k := range split(m[2]) {
+				path = join(path, k)
+				if n, ok := count[path]; ok && path != strings.Join(split(m[2]), ".") {
+					path = join(path, strconv.Itoa(n-1))
+				}
+			}
+			if m[1] == "[[" {
+				count[path]++
+				out[path] = Position{line, column}
+				path = join(path, strconv.Itoa(count[path]-1))
+			}
+			table = path
+			out[table] = Position{line, column}
+			continue
+		}
+
+		if m := locateKey.FindStringSubmatch(trim); m != nil {
+			path := table
+			for _, k := range split(m[1]) {
+				path = join(path, k)
+			}
+			out[path] = Position{line, column}
+		}
+	}
+}
+
+// DecodeError is an error of decoding a file, which tells where it fails.
+// It is aliased to `ErrDecoding`.
+//  - Position and Snippet are empty if the position is unknown.
+type DecodeError struct {
+	Name    string // name of the file
+	Format  string // format of the file, like "json"
+	Snippet string // the failed line with a caret under the column
+	Err     error  // error from the marshaler
+	Position
+	detail.Detail
+}
+
+// Error override the error interface to custom message.
+func (e *DecodeError) Error() string {
+	msg := "failed to decode " + e.Format + " `" + e.Name + "`"
+	if e.Line > 0 {
+		msg += " (" + e.Position.String() + ")"
+	}
+	msg += ": " + e.Err.Error()
+	if e.Snippet != "" {
+		msg += "\n" + e.Snippet
+	}
+	return msg
+}
+
+// Unwrap returns the error from the marshaler.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// decodeError creates a `*DecodeError` from err, which is returned by
+// the marshaler of format tag. Set blind to skip finding its position.
+func decodeError(name, tag string, bin []byte, err error, blind bool) error {
+	if err == nil {
+		return nil
+	}
+
+	e := &DecodeError{Name: name, Format: tag, Err: err}
+	if !blind {
+		e.Position = positionOfError(bin, err)
+		e.Snippet = snippet(bin, e.Position)
+	}
+	e.Detail = detail.Make(
+		e,
+		detail.FlagAlias(ErrDecoding),
+		detail.FlagStackTrace(2))
+	return e
+}
+
+var positionLine = regexp.MustCompile(`\bline (\d+)\b`)
+
+// positionOfError finds the position in bin from an error of a marshaler.
+// It knows errors of json and toml; for others, it only looks for
+// "line N" in the message.
+func positionOfError(bin []byte, err error) Position {
+	var (
+		syntax *json.SyntaxError
+		typed  *json.UnmarshalTypeError
+		parse  toml.ParseError
+	)
+
+	switch {
+	case errors.As(err, &syntax):
+		// offset is after the bad byte
+		return positionOf(bin, syntax.Offset-1)
+	case errors.As(err, &typed):
+		return positionOf(bin, typed.Offset-1)
+	case errors.As(err, &parse):
+		p := positionOf(bin, int64(parse.Position.Start))
+		if p.Line != parse.Position.Line {
+			p = Position{Line: parse.Position.Line}
+		}
+		return p
+	}
+
+	if m := positionLine.FindStringSubmatch(err.Error()); m != nil {
+		if n, _ := strconv.Atoi(m[1]); n > 0 && n <= bytes.Count(bin, []byte("\n"))+1 {
+			return Position{Line: n}
+		}
+	}
+	return Position{}
+}
+
+// snippet shows the line at p with its number, and a caret under the
+// column if known. It returns "" if p is unknown.
+func snippet(bin []byte, p Position) string {
+	lines := strings.Split(string(bin), "\n")
+	if p.Line <= 0 || p.Line > len(lines) {
+		return ""
+	}
+
+	text := strings.TrimRight(lines[p.Line-1], "\r")
+	head := strconv.Itoa(p.Line) + " | "
+	out := head + text
+	if p.Column > 0 && p.Column <= len(text)+1 {
+		// keep tabs so that the caret is aligned
+		pad := []rune{}
+		for _, r := range text[:p.Column-1] {
+			if r != '\t' {
+				r = ' '
+			}
+			pad = append(pad, r)
+		}
+		out += "\n" + strings.Repeat(" ", len(head)-2) + "| " + string(pad) + "^"
+	}
+	return out
+}
@@ -0,0 +1,57 @@
+package config_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/wiryls/pkg/config"
+)
+
+func TestDecodeError(t *testing.T) {
+	assert := assert.New(t)
+
+	type Data struct {
+		Name string `json:"name" yaml:"name" toml:"name"`
+		Port int    `json:"port" yaml:"port" toml:"port"`
+	}
+
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	for _, c := range []struct {
+		name     string
+		text     string
+		position config.Position
+		snippet  string
+	}{
+		{"syntax.json", "{\n  \"name\": \"orz\",\n  \"port\": x\n}\n", config.Position{Line: 3, Column: 11}, "3 |   \"port\": x\n  |           ^"},
+		{"type.json", "{\n  \"name\": \"orz\",\n  \"port\": \"80\"\n}\n", config.Position{Line: 3, Column: 14}, "3 |   \"port\": \"80\"\n  |              ^"},
+		{"syntax.toml", "name = \"orz\"\nport = = 80\n", config.Position{Line: 2, Column: 8}, "2 | port = = 80\n  |        ^"},
+		{"syntax.yaml", "name: orz\n\tport: 80\n", config.Position{Line: 2}, "2 | \tport: 80"},
+		{"type.yaml", "name: orz\nport: [80]\n", config.Position{Line: 2}, "2 | port: [80]"},
+	} {
+		dst := filepath.Join(dir, c.name)
+		assert.NoError(ioutil.WriteFile(dst, []byte(c.text), 0644))
+
+		err := config.Load(&Data{}, dst)
+		assert.True(errors.Is(err, config.ErrDecoding), c.name)
+
+		var e *config.DecodeError
+		if assert.True(errors.As(err, &e), c.name) {
+			assert.Equal(dst, e.Name, c.name)
+			assert.Equal(strings.TrimPrefix(filepath.Ext(c.name), "."), e.Format, c.name)
+			assert.Equal(c.position, e.Position, c.name)
+			assert.Equal(c.snippet, e.Snippet, c.name)
+			assert.NotNil(errors.Unwrap(e), c.name)
+			assert.True(strings.HasPrefix(e.Error(), "failed to decode "), c.name)
+			assert.Contains(e.Error(), c.snippet, c.name)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package config_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/wiryls/pkg/config"
+)
+
+func TestSave(t *testing.T) {
+	assert := assert.New(t)
+
+	type Data struct {
+		Message string `json:"message"`
+	}
+	type Bad struct {
+		Message chan int `json:"message"`
+	}
+
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	dst := filepath.Join(dir, "config.json")
+
+	{ // new file with mode
+		assert.NoError(config.Save(&Data{"orz"}, dst, config.OptionMode(0600)))
+		info, err := os.Stat(dst)
+		assert.NoError(err)
+		assert.Equal(os.FileMode(0600), info.Mode().Perm())
+	}
+	{ // existing file keeps its mode
+		assert.NoError(os.Chmod(dst, 0640))
+		assert.NoError(config.Save(&Data{"OTZ"}, dst))
+		info, err := os.Stat(dst)
+		assert.NoError(err)
+		assert.Equal(os.FileMode(0640), info.Mode().Perm())
+
+		out := Data{}
+		assert.NoError(config.Load(&out, dst))
+		assert.Equal("OTZ", out.Message)
+	}
+	{ // a failed encoding leaves the file untouched
+		before, err := ioutil.ReadFile(dst)
+		assert.NoError(err)
+
+		err = config.Save(&Bad{make(chan int)}, dst)
+		assert.True(errors.Is(err, config.ErrEncoding))
+
+		after, err := ioutil.ReadFile(dst)
+		assert.NoError(err)
+		assert.Equal(before, after)
+
+		list, err := ioutil.ReadDir(dir)
+		assert.NoError(err)
+		assert.Len(list, 1)
+	}
+}
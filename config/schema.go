@@ -0,0 +1,261 @@
+package config
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/wiryls/pkg/errors/cerrors"
+	"github.com/wiryls/pkg/errors/wrap"
+)
+
+// Schema generates a JSON Schema (draft-07) from the type of v.
+//  - Descriptions come from tag `desc:"..."`.
+//  - Defaults come from tag `default:"..."`.
+//  - Rules `required`, `min`, `max` and `oneof` of tag `validate:"..."`
+//    are also converted.
+func Schema(v interface{}) (schema map[string]interface{}, err error) {
+	err = cerrors.TestNilArgumentIfNoErr(err, v, "v interface{}")
+
+	if err == nil {
+		g := schemer{seen: map[reflect.Type]bool{}}
+		schema = g.schema(reflect.TypeOf(v))
+		schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+		err = g.err
+	}
+
+	return
+}
+
+// Defaults sets zero fields of v by their `default` tags. Nil pointers are
+// left unchanged.
+func Defaults(v interface{}) (err error) {
+	r := reflect.ValueOf(v)
+	err = cerrors.TestInvalidArgument(
+		r.Kind() != reflect.Ptr || r.IsNil(),
+		"v interface{}", "a non-nil pointer is required")
+
+	if err == nil {
+		err = fill(r, nil)
+	}
+
+	return
+}
+
+// Example generates an example config in the format of tag or extension
+// like "yaml". It is a fresh value of the type of v, filled by `Defaults`,
+// and commented by `desc` tags if the format supports comments.
+func (s Marshalers) Example(v interface{}, format string) (bin []byte, err error) {
+	r := reflect.ValueOf(v)
+	err = cerrors.TestInvalidArgument(
+		r.Kind() != reflect.Ptr || r.IsNil(),
+		"v interface{}", "a non-nil pointer is required")
+
+	var val interface{}
+	if err == nil {
+		val = reflect.New(r.Type().Elem()).Interface()
+		err = Defaults(val)
+	}
+
+	if err == nil {
+		bin, err = s.toBytes(nameOf(format), val, collect(nil))
+	}
+
+	return
+}
+
+// fill sets zero fields by their `default` tags recursively.
+func fill(v reflect.Value, path []string) (err error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; err == nil && i < t.NumField(); i++ {
+			f := t.Field(i)
+			switch name := fieldName(f); {
+			case isInline(f):
+				err = fill(v.Field(i), path)
+			case name != "":
+				next := append(path[:len(path):len(path)], name)
+				if tag, ok := f.Tag.Lookup("default"); ok && v.Field(i).IsZero() {
+					err = parseText(v.Field(i), tag)
+					err = wrap.MessageAliasStack(err, "cannot parse default of `"+strings.Join(next, ".")+"`", ErrParsing, 0)
+				}
+				if err == nil {
+					err = fill(v.Field(i), next)
+				}
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; err == nil && i < v.Len(); i++ {
+			err = fill(v.Index(i), append(path[:len(path):len(path)], strconv.Itoa(i)))
+		}
+	}
+
+	return
+}
+
+// descriptions collects `desc` tags of type t by field paths. Elements of
+// slices and maps share the path of their container.
+func descriptions(t reflect.Type) map[string]string {
+	out := map[string]string{}
+	seen := map[reflect.Type]bool{}
+
+	var visit func(t reflect.Type, path []string)
+	visit = func(t reflect.Type, path []string) {
+		for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct || seen[t] {
+			return
+		}
+
+		seen[t] = true
+		defer delete(seen, t)
+
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			switch name := fieldName(f); {
+			case isInline(f):
+				visit(f.Type, path)
+			case name != "":
+				next := append(path[:len(path):len(path)], name)
+				if desc := f.Tag.Get("desc"); desc != "" {
+					out[strings.Join(next, ".")] = desc
+				}
+				visit(f.Type, next)
+			}
+		}
+	}
+
+	if t != nil {
+		visit(t, nil)
+	}
+	return out
+}
+
+// schemer generates JSON Schema.
+type schemer struct {
+	seen map[reflect.Type]bool
+	err  error
+}
+
+func (g *schemer) schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	out := map[string]interface{}{}
+	switch {
+	case reflect.PtrTo(t).Implements(typeTextUnmarshaler):
+		out["type"] = "string"
+		return out
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		out["type"] = "string"
+	case reflect.Bool:
+		out["type"] = "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		out["type"] = "integer"
+	case reflect.Float32, reflect.Float64:
+		out["type"] = "number"
+	case reflect.Slice, reflect.Array:
+		out["type"] = "array"
+		out["items"] = g.schema(t.Elem())
+	case reflect.Map:
+		out["type"] = "object"
+		out["additionalProperties"] = g.schema(t.Elem())
+	case reflect.Struct:
+		out["type"] = "object"
+		if g.seen[t] {
+			break // recursive types are left open
+		}
+
+		g.seen[t] = true
+		defer delete(g.seen, t)
+
+		props := map[string]interface{}{}
+		required := []string{}
+		g.fields(t, props, &required)
+
+		out["properties"] = props
+		if len(required) != 0 {
+			out["required"] = required
+		}
+	}
+
+	return out
+}
+
+func (g *schemer) fields(t reflect.Type, props map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := fieldName(f)
+		switch {
+		case isInline(f):
+			e := f.Type
+			if e.Kind() == reflect.Ptr {
+				e = e.Elem()
+			}
+			g.fields(e, props, required)
+			continue
+		case name == "":
+			continue
+		}
+
+		prop := g.schema(f.Type)
+		if desc := f.Tag.Get("desc"); desc != "" {
+			prop["description"] = desc
+		}
+
+		if tag, ok := f.Tag.Lookup("default"); ok {
+			v := reflect.New(f.Type).Elem()
+			if err := parseText(v, tag); err != nil {
+				if g.err == nil {
+					g.err = wrap.MessageAliasStack(err, "cannot parse default of `"+name+"`", ErrParsing, 0)
+				}
+			} else {
+				prop["default"] = v.Interface()
+			}
+		}
+
+		for _, rule := range strings.Split(f.Tag.Get("validate"), ",") {
+			rule = strings.TrimSpace(rule)
+			switch {
+			case rule == "required":
+				*required = append(*required, name)
+			case strings.HasPrefix(rule, "oneof="):
+				list := []interface{}{}
+				for _, item := range strings.Split(strings.TrimPrefix(rule, "oneof="), "|") {
+					list = append(list, item)
+				}
+				prop["enum"] = list
+			case strings.HasPrefix(rule, "min="), strings.HasPrefix(rule, "max="):
+				n, err := strconv.ParseFloat(rule[4:], 64)
+				kind, ok := prop["type"].(string)
+				if err != nil || !ok {
+					continue // untyped properties have no bounds
+				}
+				key := map[string]map[string]string{
+					"min=": {"string": "minLength", "array": "minItems", "object": "minProperties", "integer": "minimum", "number": "minimum"},
+					"max=": {"string": "maxLength", "array": "maxItems", "object": "maxProperties", "integer": "maximum", "number": "maximum"},
+				}[rule[:4]][kind]
+				if key != "" {
+					prop[key] = n
+				}
+			}
+		}
+
+		props[name] = prop
+	}
+}
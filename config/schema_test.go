@@ -0,0 +1,107 @@
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/wiryls/pkg/config"
+)
+
+type schemaDB struct {
+	Host    string        `json:"host" yaml:"host" toml:"host" default:"localhost" desc:"host of database"`
+	Port    int           `json:"port" yaml:"port" toml:"port" default:"5432" validate:"required,min=1,max=65535"`
+	Timeout time.Duration `json:"timeout" yaml:"timeout" toml:"timeout" default:"3s"`
+}
+
+type schemaData struct {
+	Level string            `json:"level" yaml:"level" toml:"level" default:"info" desc:"log level" validate:"oneof=debug|info"`
+	Tags  []string          `json:"tags" yaml:"tags" toml:"tags"`
+	Env   map[string]string `json:"env" yaml:"env" toml:"env"`
+	DB    schemaDB          `json:"db" yaml:"db" toml:"db" desc:"database\nsettings"`
+}
+
+func TestSchema(t *testing.T) {
+	assert := assert.New(t)
+
+	schema, err := config.Schema(&schemaData{})
+	assert.NoError(err)
+	assert.Equal("object", schema["type"])
+
+	props := schema["properties"].(map[string]interface{})
+	level := props["level"].(map[string]interface{})
+	assert.Equal("string", level["type"])
+	assert.Equal("log level", level["description"])
+	assert.Equal("info", level["default"])
+	assert.Equal([]interface{}{"debug", "info"}, level["enum"])
+
+	assert.Equal("array", props["tags"].(map[string]interface{})["type"])
+	assert.Equal("object", props["env"].(map[string]interface{})["type"])
+
+	db := props["db"].(map[string]interface{})
+	assert.Equal([]string{"port"}, db["required"])
+	port := db["properties"].(map[string]interface{})["port"].(map[string]interface{})
+	assert.Equal("integer", port["type"])
+	assert.Equal(5432, port["default"])
+	assert.Equal(1.0, port["minimum"])
+	assert.Equal(65535.0, port["maximum"])
+
+	{ // untyped properties
+		type Data struct {
+			Any interface{} `json:"any" validate:"min=1,max=2"`
+		}
+		schema, err := config.Schema(&Data{})
+		assert.NoError(err)
+		assert.Equal(map[string]interface{}{}, schema["properties"].(map[string]interface{})["any"])
+	}
+}
+
+func TestExample(t *testing.T) {
+	assert := assert.New(t)
+
+	{ // yaml
+		bin, err := config.Example(&schemaData{}, "yaml")
+		assert.NoError(err)
+		assert.Contains(string(bin), "# log level\nlevel: info\n")
+		assert.Contains(string(bin), "# database\n# settings\ndb:\n")
+		assert.Contains(string(bin), "    # host of database\n    host: localhost\n")
+	}
+	{ // toml
+		bin, err := config.Example(&schemaData{}, "toml")
+		assert.NoError(err)
+		assert.Contains(string(bin), "# log level\nlevel = \"info\"\n")
+		assert.Contains(string(bin), "# database\n# settings\n[db]\n")
+		assert.Contains(string(bin), "  # host of database\n  host = \"localhost\"\n")
+	}
+	{ // json has no comments
+		bin, err := config.Example(&schemaData{}, "json")
+		assert.NoError(err)
+		assert.Contains(string(bin), `"port": 5432`)
+	}
+	{ // scaffolding
+		dir, err := ioutil.TempDir("", "config")
+		assert.NoError(err)
+		defer os.RemoveAll(dir)
+
+		dst := filepath.Join(dir, "config.yaml")
+		out := schemaData{Level: "debug"}
+		path, find, err := config.LoadOrSaveSome(&out, []string{dst})
+		assert.NoError(err)
+		assert.False(find)
+		assert.Equal(dst, path)
+		assert.Equal("debug", out.Level)
+		assert.Equal(3*time.Second, out.DB.Timeout)
+
+		bin, err := ioutil.ReadFile(dst)
+		assert.NoError(err)
+		assert.Contains(string(bin), "# log level\nlevel: debug\n")
+
+		out = schemaData{}
+		assert.NoError(config.Load(&out, dst))
+		assert.Equal(5432, out.DB.Port)
+	}
+}
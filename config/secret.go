@@ -0,0 +1,270 @@
+package config
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/wiryls/pkg/errors/wrap"
+)
+
+// KeyProvider provides a 32-byte key to encrypt and decrypt secret fields.
+type KeyProvider interface {
+	Key() ([]byte, error)
+}
+
+// KeyFunc is an adapter to use a function as a `KeyProvider`.
+type KeyFunc func() ([]byte, error)
+
+// Key calls f().
+func (f KeyFunc) Key() ([]byte, error) {
+	return f()
+}
+
+// KeyFile reads a key from a file. The file contains either 32 raw bytes
+// or a base64 encoded key.
+func KeyFile(path string) KeyProvider {
+	return KeyFunc(func() ([]byte, error) {
+		bin, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, wrap.MessageAliasStack(err, "cannot read key file `"+path+"`", ErrReading, 0)
+		}
+		return parseKey(bin)
+	})
+}
+
+// KeyEnv reads a base64 encoded key from an environment variable.
+func KeyEnv(name string) KeyProvider {
+	return KeyFunc(func() ([]byte, error) {
+		raw, ok := os.LookupEnv(name)
+		if !ok {
+			return nil, wrap.MessageStack(ErrReading, "cannot find key $"+name, 0)
+		}
+		return parseKey([]byte(raw))
+	})
+}
+
+// OptionSecrets decrypts secret fields after decoding and encrypts them
+// before encoding, with the key from kp.
+//  - Secret fields are strings tagged with `secret:"true"`.
+//  - Encrypted values look like `ENC[aes256:...]`. Other values of secret
+//    fields are left unchanged while loading.
+func OptionSecrets(kp KeyProvider) Option {
+	return func(o *options) { o.keys = kp }
+}
+
+// Encrypt a plaintext to the form `ENC[aes256:...]`, which could be used
+// in config files directly.
+func Encrypt(kp KeyProvider, plaintext string) (string, error) {
+	key, err := kp.Key()
+	if err != nil {
+		return "", err
+	}
+	return encrypt(key, plaintext)
+}
+
+const (
+	secretPrefix = "ENC[aes256:"
+	secretSuffix = "]"
+)
+
+var errKeySize = errors.New("key must be 32 bytes")
+
+func parseKey(bin []byte) ([]byte, error) {
+	if len(bin) == 32 {
+		return bin, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(bin)))
+	if err == nil && len(key) != 32 {
+		err = errKeySize
+	}
+	if err != nil {
+		return nil, wrap.MessageAliasStack(err, "invalid key", ErrParsing, 0)
+	}
+	return key, nil
+}
+
+func encrypt(key []byte, plaintext string) (text string, err error) {
+	var gcm cipher.AEAD
+	if err == nil {
+		gcm, err = aead(key)
+	}
+
+	nonce := []byte(nil)
+	if err == nil {
+		nonce = make([]byte, gcm.NonceSize())
+		_, err = io.ReadFull(rand.Reader, nonce)
+	}
+
+	if err == nil {
+		bin := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+		text = secretPrefix + base64.StdEncoding.EncodeToString(bin) + secretSuffix
+	}
+
+	return
+}
+
+func decrypt(key []byte, text string) (plaintext string, err error) {
+	var gcm cipher.AEAD
+	if err == nil {
+		gcm, err = aead(key)
+	}
+
+	var bin []byte
+	if err == nil {
+		raw := strings.TrimSuffix(strings.TrimPrefix(text, secretPrefix), secretSuffix)
+		bin, err = base64.StdEncoding.DecodeString(raw)
+	}
+
+	if err == nil && len(bin) < gcm.NonceSize() {
+		err = errors.New("ciphertext too short")
+	}
+
+	if err == nil {
+		n := gcm.NonceSize()
+		bin, err = gcm.Open(nil, bin[:n], bin[n:], nil)
+		plaintext = string(bin)
+	}
+
+	return
+}
+
+func aead(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, errKeySize
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func isEncrypted(text string) bool {
+	return strings.HasPrefix(text, secretPrefix) && strings.HasSuffix(text, secretSuffix)
+}
+
+// secrets calls fn with each secret field of v.
+func secrets(v reflect.Value, path []string, fn func(path []string, v reflect.Value) error) (err error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		if v.Kind() == reflect.Interface && v.CanSet() && v.Elem().Kind() != reflect.Ptr {
+			// values in interfaces are not addressable, so edit a copy
+			e := reflect.New(v.Elem().Type()).Elem()
+			e.Set(v.Elem())
+			if err = secrets(e, path, fn); err == nil {
+				v.Set(e)
+			}
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; err == nil && i < t.NumField(); i++ {
+			f := t.Field(i)
+			switch name := fieldName(f); {
+			case isInline(f):
+				err = secrets(v.Field(i), path, fn)
+			case name == "":
+			case f.Tag.Get("secret") == "true" && f.Type.Kind() == reflect.String:
+				err = fn(append(path[:len(path):len(path)], name), v.Field(i))
+			default:
+				err = secrets(v.Field(i), append(path[:len(path):len(path)], name), fn)
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; err == nil && i < v.Len(); i++ {
+			err = secrets(v.Index(i), append(path[:len(path):len(path)], strconv.Itoa(i)), fn)
+		}
+
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			// map values are not addressable, so edit a copy and put it
+			// back
+			e := reflect.New(v.Type().Elem()).Elem()
+			e.Set(v.MapIndex(k))
+			if err == nil {
+				err = secrets(e, append(path[:len(path):len(path)], k.String()), fn)
+			}
+			if err == nil {
+				v.SetMapIndex(k, e)
+			}
+		}
+	}
+
+	return
+}
+
+// unseal decrypts secret fields of dst in place.
+func unseal(dst interface{}, kp KeyProvider) (err error) {
+	var key []byte
+	err = secrets(reflect.ValueOf(dst), nil, func(path []string, v reflect.Value) (err error) {
+		if !isEncrypted(v.String()) || !v.CanSet() {
+			return
+		}
+
+		if key == nil {
+			key, err = kp.Key()
+		}
+
+		var text string
+		if err == nil {
+			text, err = decrypt(key, v.String())
+			err = wrap.MessageAliasStack(err, "cannot decrypt `"+strings.Join(path, ".")+"`", ErrDecoding, 0)
+		}
+
+		if err == nil {
+			v.SetString(text)
+		}
+		return
+	})
+	return
+}
+
+// seal returns a copy of src with secret fields encrypted.
+func seal(src interface{}, kp KeyProvider) (out interface{}, err error) {
+	var key []byte
+	var val = clone(reflect.ValueOf(src))
+	err = secrets(val, nil, func(path []string, v reflect.Value) (err error) {
+		if isEncrypted(v.String()) || !v.CanSet() {
+			return
+		}
+
+		if key == nil {
+			key, err = kp.Key()
+		}
+
+		var text string
+		if err == nil {
+			text, err = encrypt(key, v.String())
+			err = wrap.MessageAliasStack(err, "cannot encrypt `"+strings.Join(path, ".")+"`", ErrEncoding, 0)
+		}
+
+		if err == nil {
+			v.SetString(text)
+		}
+		return
+	})
+
+	if err == nil {
+		out = val.Interface()
+	}
+	return
+}
@@ -0,0 +1,96 @@
+package config_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/wiryls/pkg/config"
+)
+
+func TestSecrets(t *testing.T) {
+	assert := assert.New(t)
+
+	type DB struct {
+		User     string `json:"user" yaml:"user"`
+		Password string `json:"password" yaml:"password" secret:"true"`
+	}
+	type Data struct {
+		DB    DB            `json:"db" yaml:"db"`
+		Peers []DB          `json:"peers" yaml:"peers"`
+		Named map[string]DB `json:"named" yaml:"named"`
+	}
+
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+	keyfile := filepath.Join(dir, "key")
+	assert.NoError(ioutil.WriteFile(keyfile, []byte(base64.StdEncoding.EncodeToString(key)), 0600))
+	os.Setenv("TEST_CONFIG_KEY", base64.StdEncoding.EncodeToString(key))
+	defer os.Unsetenv("TEST_CONFIG_KEY")
+
+	in := Data{
+		DB:    DB{"root", "p@ss"},
+		Peers: []DB{{"peer", "w0rd"}},
+		Named: map[string]DB{"main": {"admin", "s3cret"}},
+	}
+
+	for _, ext := range []string{"json", "yaml"} {
+		dst := filepath.Join(dir, "config."+ext)
+		assert.NoError(config.Save(&in, dst, config.OptionSecrets(config.KeyFile(keyfile))))
+		assert.Equal("p@ss", in.DB.Password)
+
+		bin, err := ioutil.ReadFile(dst)
+		assert.NoError(err)
+		assert.NotContains(string(bin), "p@ss")
+		assert.NotContains(string(bin), "w0rd")
+		assert.NotContains(string(bin), "s3cret")
+		assert.Equal(3, strings.Count(string(bin), "ENC[aes256:"))
+		assert.Equal("s3cret", in.Named["main"].Password)
+
+		out := Data{}
+		assert.NoError(config.Load(&out, dst, config.OptionSecrets(config.KeyEnv("TEST_CONFIG_KEY"))))
+		assert.Equal(in, out)
+
+		out = Data{}
+		assert.NoError(config.Load(&out, dst))
+		assert.True(strings.HasPrefix(out.DB.Password, "ENC[aes256:"))
+		assert.True(strings.HasPrefix(out.Named["main"].Password, "ENC[aes256:"))
+	}
+	{ // inside interfaces
+		in := map[string]interface{}{"db": DB{"root", "p@ss"}}
+		dst := filepath.Join(dir, "any.json")
+		assert.NoError(config.Save(in, dst, config.OptionSecrets(config.KeyFile(keyfile))))
+		assert.Equal(DB{"root", "p@ss"}, in["db"])
+
+		bin, err := ioutil.ReadFile(dst)
+		assert.NoError(err)
+		assert.NotContains(string(bin), "p@ss")
+	}
+	{ // encrypt by hand
+		text, err := config.Encrypt(config.KeyFile(keyfile), "secret")
+		assert.NoError(err)
+
+		dst := filepath.Join(dir, "hand.json")
+		assert.NoError(ioutil.WriteFile(dst, []byte(`{"db": {"user": "secret", "password": "`+text+`"}}`), 0644))
+
+		out := Data{}
+		assert.NoError(config.Load(&out, dst, config.OptionSecrets(config.KeyFile(keyfile))))
+		assert.Equal("secret", out.DB.Password)
+	}
+	{ // wrong key
+		bad := config.KeyFunc(func() ([]byte, error) { return []byte("fedcba9876543210fedcba9876543210"), nil })
+
+		out := Data{}
+		err := config.Load(&out, filepath.Join(dir, "config.json"), config.OptionSecrets(bad))
+		assert.True(errors.Is(err, config.ErrDecoding))
+	}
+}
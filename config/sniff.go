@@ -0,0 +1,54 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+var (
+	sniffTable = regexp.MustCompile(`^\[\[?\s*[\w\-."' ]+\s*\]\]?\s*(#.*)?$`)
+	sniffTOML  = regexp.MustCompile(`^[\w\-."' ]+\s*=`)
+	sniffYAML  = regexp.MustCompile(`^([\w\-."' ]+\s*:(\s|$)|-(\s|$)|%YAML)`)
+)
+
+// sniff guesses the format of a config from its content. It returns a tag
+// like "json", "toml" or "yaml", or "" if unknown.
+//  - A leading `{`, or valid JSON starting with `[`, means JSON.
+//  - A `[table]` or `key = value` line means TOML.
+//  - A `---`, `key: value` or `- item` line means YAML.
+func sniff(bin []byte) string {
+	bin = bytes.TrimPrefix(bin, []byte("\xef\xbb\xbf"))
+
+	// arrays of JSON may look like tables of TOML
+	if text := bytes.TrimSpace(bin); bytes.HasPrefix(text, []byte("[")) && json.Valid(text) {
+		return "json"
+	}
+
+	scan := bufio.NewScanner(bytes.NewReader(bin))
+	for scan.Scan() {
+		line := strings.TrimSpace(scan.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "{"):
+			return "json"
+		case strings.HasPrefix(line, "---"):
+			return "yaml"
+		case sniffTable.MatchString(line):
+			return "toml"
+		case strings.HasPrefix(line, "["):
+			return "json"
+		case sniffTOML.MatchString(line):
+			return "toml"
+		case sniffYAML.MatchString(line):
+			return "yaml"
+		default:
+			return ""
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,66 @@
+package config_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/wiryls/pkg/config"
+)
+
+func TestSniff(t *testing.T) {
+	assert := assert.New(t)
+
+	type Server struct {
+		Name string `json:"name" yaml:"name" toml:"name"`
+	}
+	type Data struct {
+		Name   string `json:"name"   yaml:"name"   toml:"name"`
+		Port   int    `json:"port"   yaml:"port"   toml:"port"`
+		Server Server `json:"server" yaml:"server" toml:"server"`
+	}
+
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	for name, c := range map[string]struct {
+		text string
+		want Data
+	}{
+		"config":     {"\n  {\"name\": \"json\", \"port\": 1}", Data{Name: "json", Port: 1}},
+		".apprc":     {"# comment\nname = \"toml\"\nport = 1\n", Data{Name: "toml", Port: 1}},
+		"app.conf":   {"---\nname: yaml\nport: 1\n", Data{Name: "yaml", Port: 1}},
+		"app.cfg":    {"name: yaml\nport: 1\n", Data{Name: "yaml", Port: 1}},
+		"app.ini":    {"[server]\nname = \"toml\"\n", Data{Server: Server{Name: "toml"}}},
+		"app.config": {"\xef\xbb\xbfname: yaml\n", Data{Name: "yaml"}},
+	} {
+		path := filepath.Join(dir, name)
+		assert.NoError(ioutil.WriteFile(path, []byte(c.text), 0644))
+
+		out := Data{}
+		assert.NoError(config.Load(&out, path), name)
+		assert.Equal(c.want, out, name)
+	}
+
+	{ // arrays of JSON are not tables of TOML
+		path := filepath.Join(dir, "list")
+		assert.NoError(ioutil.WriteFile(path, []byte("[\"a\"]\n"), 0644))
+
+		out := []string{}
+		assert.NoError(config.Load(&out, path))
+		assert.Equal([]string{"a"}, out)
+	}
+	{ // unknown content
+		path := filepath.Join(dir, "app.txt")
+		assert.NoError(ioutil.WriteFile(path, []byte("orz"), 0644))
+
+		out := Data{}
+		err := config.Load(&out, path)
+		assert.True(errors.Is(err, config.ErrDecoding))
+	}
+}
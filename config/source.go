@@ -0,0 +1,392 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"io/fs"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wiryls/pkg/errors/cerrors"
+	"github.com/wiryls/pkg/errors/wrap"
+)
+
+// Source provides the content of a config from anywhere, such as a local
+// file, an `fs.FS`, a remote URL or memory.
+type Source interface {
+	// Read returns the content and its format, a tag or an extension like
+	// "json" or ".yaml". The format may be empty or unknown to detect it by
+	// content.
+	Read(ctx context.Context) (bin []byte, format string, err error)
+	// Watch returns a channel that receives a value when the content may
+	// have changed. The channel is closed after ctx is done.
+	Watch(ctx context.Context) (<-chan struct{}, error)
+}
+
+// LoadSource reads a configuration from src.
+func (s Marshalers) LoadSource(ctx context.Context, dst interface{}, src Source, opts ...Option) (err error) {
+	err = cerrors.TestNilArgumentIfNoErr(err, src, "src Source")
+	err = cerrors.TestNilArgumentIfNoErr(err, dst, "dst interface{}")
+	o := collect(opts)
+	if err == nil {
+		err = s.fromSource(ctx, src, dst, o)
+	}
+	if err == nil {
+		err = o.overlay(dst)
+	}
+	return
+}
+
+// LoadSomeSource is the `Source` version of `LoadSome`. It returns the
+// first source loaded.
+func (s Marshalers) LoadSomeSource(ctx context.Context, dst interface{}, list []Source, opts ...Option) (src Source, err error) {
+
+	err = cerrors.TestNilArgumentIfNoErr(err, list, "list []Source")
+	err = cerrors.TestNilArgumentIfNoErr(err, dst, "dst interface{}")
+
+	o := collect(opts)
+	if err == nil {
+		var i int
+		i, err = some(len(list), func(i int) error { return s.fromSource(ctx, list[i], dst, o) })
+		if err == nil {
+			src = list[i]
+		}
+	}
+
+	if err == nil {
+		err = o.overlay(dst)
+	}
+
+	return
+}
+
+func (s Marshalers) fromSource(ctx context.Context, src Source, dst interface{}, o *options) (err error) {
+	err = cerrors.TestNilArgumentIfNoErr(err, src, "src Source")
+
+	var bin []byte
+	var format string
+	if err == nil {
+		bin, format, err = src.Read(ctx)
+	}
+
+	if err == nil {
+		// an unknown format is detected by content
+		c := *o
+		c.tag = s.format("."+strings.TrimPrefix(format, "."), bin)
+		err = s.fromBytes(nameOfSource(src), bin, dst, &c)
+	}
+
+	return
+}
+
+// nameOfSource is used in messages of errors.
+func nameOfSource(src Source) string {
+	if s, ok := src.(interface{ String() string }); ok {
+		return s.String()
+	}
+	return "source"
+}
+
+////////////////////////////////// file /////////////////////////////////////
+
+// FileSource reads a local file. It is watched by polling the modification
+// time and size.
+type FileSource struct {
+	// Path to the file.
+	Path string
+	// Interval between two polls. Default to one second.
+	Interval time.Duration
+}
+
+// SourceFile creates a `FileSource` of path.
+func SourceFile(path string) *FileSource {
+	return &FileSource{Path: path, Interval: time.Second}
+}
+
+// Read the file. The format is its extension.
+func (s *FileSource) Read(context.Context) ([]byte, string, error) {
+	bin, err := ioutil.ReadFile(s.Path)
+	if err != nil {
+		return nil, "", wrap.MessageAliasStack(err, "cannot read `"+s.Path+"`", ErrReading, 0)
+	}
+	return bin, filepath.Ext(s.Path), nil
+}
+
+// Watch polls the file until ctx is done.
+func (s *FileSource) Watch(ctx context.Context) (<-chan struct{}, error) {
+	return poll(ctx, s.Interval, time.Second, func() interface{} {
+		if info, err := os.Stat(s.Path); err == nil {
+			return stamp{info.ModTime(), info.Size(), true}
+		}
+		return stamp{}
+	}), nil
+}
+
+// String returns the path.
+func (s *FileSource) String() string {
+	return s.Path
+}
+
+/////////////////////////////////// fs //////////////////////////////////////
+
+// FSSource reads a file of an `fs.FS`. It is watched by polling the
+// modification time and size, which may never change for some file systems
+// like `embed.FS`.
+type FSSource struct {
+	// FS holds the file.
+	FS fs.FS
+	// Path to the file in FS.
+	Path string
+	// Interval between two polls. Default to one second.
+	Interval time.Duration
+}
+
+// SourceFS creates a `FSSource` of path in fsys.
+func SourceFS(fsys fs.FS, path string) *FSSource {
+	return &FSSource{FS: fsys, Path: path, Interval: time.Second}
+}
+
+// Read the file. The format is its extension.
+func (s *FSSource) Read(context.Context) (bin []byte, format string, err error) {
+	err = cerrors.TestNilArgumentIfNoErr(err, s.FS, "FS fs.FS")
+	if err == nil {
+		bin, err = fs.ReadFile(s.FS, s.Path)
+		err = wrap.MessageAliasStack(err, "cannot read `"+s.Path+"`", ErrReading, 0)
+	}
+	if err == nil {
+		format = path.Ext(s.Path)
+	}
+	return
+}
+
+// Watch polls the file until ctx is done.
+func (s *FSSource) Watch(ctx context.Context) (<-chan struct{}, error) {
+	err := cerrors.TestNilArgumentIfNoErr(nil, s.FS, "FS fs.FS")
+	if err != nil {
+		return nil, err
+	}
+	return poll(ctx, s.Interval, time.Second, func() interface{} {
+		if info, err := fs.Stat(s.FS, s.Path); err == nil {
+			return stamp{info.ModTime(), info.Size(), true}
+		}
+		return stamp{}
+	}), nil
+}
+
+// String returns the path.
+func (s *FSSource) String() string {
+	return s.Path
+}
+
+////////////////////////////////// http /////////////////////////////////////
+
+// HTTPSource gets a config from a URL. The body is read into memory, and
+// no temporary file is created.
+//  - The format comes from the "Content-Type" of the response, or the
+//    extension of the URL if the type is unknown.
+//  - It is watched by polling the URL and comparing digests of bodies.
+type HTTPSource struct {
+	// URL to get.
+	URL string
+	// Client to send requests. Default to `http.DefaultClient`.
+	Client *http.Client
+	// Header added to each request. Optional.
+	Header http.Header
+	// Interval between two polls. Default to 30 seconds.
+	Interval time.Duration
+}
+
+// SourceHTTP creates a `HTTPSource` of url.
+func SourceHTTP(url string) *HTTPSource {
+	return &HTTPSource{URL: url, Interval: 30 * time.Second}
+}
+
+// Read gets the URL. Responses other than 2xx are errors.
+func (s *HTTPSource) Read(ctx context.Context) (bin []byte, format string, err error) {
+	var req *http.Request
+	if err == nil {
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+		err = wrap.MessageAliasStack(err, "cannot request `"+s.URL+"`", ErrReading, 0)
+	}
+
+	var rsp *http.Response
+	if err == nil {
+		for k, v := range s.Header {
+			req.Header[k] = append([]string(nil), v...)
+		}
+
+		client := s.Client
+		if client == nil {
+			client = http.DefaultClient
+		}
+		rsp, err = client.Do(req)
+		err = wrap.MessageAliasStack(err, "cannot get `"+s.URL+"`", ErrReading, 0)
+	}
+
+	if err == nil {
+		defer rsp.Body.Close()
+		if rsp.StatusCode < 200 || rsp.StatusCode > 299 {
+			err = wrap.MessageStack(ErrReading, "cannot get `"+s.URL+"`: "+rsp.Status, 0)
+		}
+	}
+
+	if err == nil {
+		bin, err = ioutil.ReadAll(rsp.Body)
+		err = wrap.MessageAliasStack(err, "cannot read `"+s.URL+"`", ErrReading, 0)
+	}
+
+	if err == nil {
+		format = formatOfType(rsp.Header.Get("Content-Type"))
+		if format == "" {
+			if u, e := url.Parse(s.URL); e == nil {
+				format = path.Ext(u.Path)
+			}
+		}
+	}
+
+	return
+}
+
+// Watch polls the URL until ctx is done. Failed requests are ignored.
+func (s *HTTPSource) Watch(ctx context.Context) (<-chan struct{}, error) {
+	return poll(ctx, s.Interval, 30*time.Second, func() interface{} {
+		if bin, format, err := s.Read(ctx); err == nil {
+			return [2]interface{}{sha256.Sum256(bin), format}
+		}
+		return nil
+	}), nil
+}
+
+// String returns the URL.
+func (s *HTTPSource) String() string {
+	return s.URL
+}
+
+// formatOfType returns the tag of a media type, or "" if unknown.
+func formatOfType(media string) string {
+	media, _, _ = mime.ParseMediaType(media)
+	switch media {
+	case "application/json", "text/json":
+		return "json"
+	case "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+		return "yaml"
+	case "application/toml", "text/toml":
+		return "toml"
+	default:
+		return ""
+	}
+}
+
+///////////////////////////////// memory ////////////////////////////////////
+
+// MemorySource holds a config in memory, which is useful in tests. Call
+// `Set` to change it and notify watchers.
+type MemorySource struct {
+	lock   sync.Mutex
+	bin    []byte
+	format string
+	subs   map[chan struct{}]struct{}
+}
+
+// SourceMemory creates a `MemorySource` with bin in format.
+func SourceMemory(bin []byte, format string) *MemorySource {
+	return &MemorySource{bin: bin, format: format}
+}
+
+// Set replaces the content and notifies watchers.
+func (s *MemorySource) Set(bin []byte, format string) {
+	defer s.lock.Unlock()
+	/*_*/ s.lock.Lock()
+
+	s.bin, s.format = bin, format
+	for c := range s.subs {
+		select {
+		case c <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Read returns a copy of the content.
+func (s *MemorySource) Read(context.Context) ([]byte, string, error) {
+	defer s.lock.Unlock()
+	/*_*/ s.lock.Lock()
+
+	return append([]byte(nil), s.bin...), s.format, nil
+}
+
+// Watch notifies each `Set` until ctx is done.
+func (s *MemorySource) Watch(ctx context.Context) (<-chan struct{}, error) {
+	c := make(chan struct{}, 1)
+
+	s.lock.Lock()
+	if s.subs == nil {
+		s.subs = make(map[chan struct{}]struct{})
+	}
+	s.subs[c] = struct{}{}
+	s.lock.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		defer s.lock.Unlock()
+		/*_*/ s.lock.Lock()
+
+		delete(s.subs, c)
+		close(c)
+	}()
+
+	return c, nil
+}
+
+// String returns "memory".
+func (s *MemorySource) String() string {
+	return "memory"
+}
+
+///////////////////////////////// helpers ///////////////////////////////////
+
+// poll calls probe every interval (or def if not positive) until ctx is
+// done, and notifies if the result differs from the last one. Results must
+// be comparable, and nil means unknown.
+func poll(ctx context.Context, interval, def time.Duration, probe func() interface{}) <-chan struct{} {
+	if interval <= 0 {
+		interval = def
+	}
+
+	c := make(chan struct{}, 1)
+	last := probe()
+
+	go func() {
+		defer close(c)
+
+		tick := time.NewTicker(interval)
+		defer tick.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+			}
+
+			if curr := probe(); curr != nil && curr != last {
+				last = curr
+				select {
+				case c <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	return c
+}
@@ -0,0 +1,198 @@
+package config_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/wiryls/pkg/config"
+	"github.com/wiryls/pkg/runner"
+)
+
+func TestLoadSource(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	type Data struct {
+		Message string `json:"message" yaml:"message" toml:"message"`
+	}
+
+	{ // memory
+		out := Data{}
+		src := config.SourceMemory([]byte(`message: orz`), "yaml")
+		assert.NoError(config.LoadSource(ctx, &out, src))
+		assert.Equal("orz", out.Message)
+
+		// detected by content
+		src.Set([]byte(`{"message": "OTZ"}`), "")
+		assert.NoError(config.LoadSource(ctx, &out, src))
+		assert.Equal("OTZ", out.Message)
+	}
+	{ // file
+		dir, err := ioutil.TempDir("", "")
+		assert.NoError(err)
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "config.toml")
+		assert.NoError(ioutil.WriteFile(path, []byte(`message = "orz"`), 0644))
+
+		out := Data{}
+		assert.NoError(config.LoadSource(ctx, &out, config.SourceFile(path)))
+		assert.Equal("orz", out.Message)
+
+		// unknown extensions are detected by content
+		path = filepath.Join(dir, ".apprc")
+		assert.NoError(ioutil.WriteFile(path, []byte(`message: OTZ`), 0644))
+		assert.NoError(config.LoadSource(ctx, &out, config.SourceFile(path)))
+		assert.Equal("OTZ", out.Message)
+
+		err = config.LoadSource(ctx, &out, config.SourceFile(filepath.Join(dir, "none.json")))
+		assert.True(errors.Is(err, config.ErrReading))
+	}
+	{ // fs
+		fsys := fstest.MapFS{"a/config.json": {Data: []byte(`{"message": "orz"}`)}}
+
+		out := Data{}
+		assert.NoError(config.LoadSource(ctx, &out, config.SourceFS(fsys, "a/config.json")))
+		assert.Equal("orz", out.Message)
+	}
+	{ // http
+		mux := http.NewServeMux()
+		mux.HandleFunc("/typed", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/x-yaml; charset=utf-8")
+			w.Write([]byte(`message: ` + r.Header.Get("X-Message")))
+		})
+		mux.HandleFunc("/config.toml", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write([]byte(`message = "orz"`))
+		})
+		svr := httptest.NewServer(mux)
+		defer svr.Close()
+
+		out := Data{}
+		src := config.SourceHTTP(svr.URL + "/typed")
+		src.Header = http.Header{"X-Message": {"OTZ"}}
+		assert.NoError(config.LoadSource(ctx, &out, src))
+		assert.Equal("OTZ", out.Message)
+
+		assert.NoError(config.LoadSource(ctx, &out, config.SourceHTTP(svr.URL+"/config.toml?v=1")))
+		assert.Equal("orz", out.Message)
+
+		err := config.LoadSource(ctx, &out, config.SourceHTTP(svr.URL+"/none"))
+		assert.True(errors.Is(err, config.ErrReading))
+	}
+	{ // decoding errors name the source
+		out := Data{}
+		err := config.LoadSource(ctx, &out, config.SourceMemory([]byte(`{orz`), "json"))
+		assert.True(errors.Is(err, config.ErrDecoding))
+		assert.Contains(err.Error(), "`memory`")
+	}
+	{ // some
+		out := Data{}
+		good := config.SourceMemory([]byte(`message: orz`), ".yaml")
+		src, err := config.LoadSomeSource(ctx, &out, []config.Source{
+			config.SourceFile(filepath.Join(os.TempDir(), "none.json")),
+			good,
+		})
+		assert.NoError(err)
+		assert.Equal(good, src)
+		assert.Equal("orz", out.Message)
+
+		_, err = config.LoadSomeSource(ctx, &out, []config.Source{})
+		assert.Error(err)
+	}
+}
+
+func TestSourceWatch(t *testing.T) {
+	assert := assert.New(t)
+
+	{ // memory
+		ctx, cancel := context.WithCancel(context.Background())
+		src := config.SourceMemory(nil, "json")
+		c, err := src.Watch(ctx)
+		assert.NoError(err)
+
+		src.Set([]byte(`{}`), "json")
+		select {
+		case <-c:
+		case <-time.After(time.Second):
+			assert.Fail("timeout")
+		}
+
+		cancel()
+		for range c {
+		}
+	}
+	{ // http
+		body := atomic.Value{}
+		body.Store("{}")
+		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body.Load().(string)))
+		}))
+		defer svr.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		src := config.SourceHTTP(svr.URL)
+		src.Interval = 5 * time.Millisecond
+		c, err := src.Watch(ctx)
+		assert.NoError(err)
+
+		body.Store(`{"message": "orz"}`)
+		select {
+		case <-c:
+		case <-time.After(time.Second):
+			assert.Fail("timeout")
+		}
+
+		cancel()
+		for range c {
+		}
+	}
+}
+
+func TestWatchSource(t *testing.T) {
+	assert := assert.New(t)
+
+	type Data struct {
+		Message string `json:"message"`
+	}
+
+	src := config.SourceMemory([]byte(`{"message": "orz"}`), "json")
+	w := config.WatchSource(&Data{Message: "orz"}, src)
+
+	changes := make(chan []config.Change, 1)
+	w.Fields.Subscribe("message", func(c []config.Change) { changes <- c })
+
+	var r runner.Runner = w
+	done := make(chan error)
+	go func() { done <- r.Run() }()
+	for w.State() != runner.StateRunning {
+		time.Sleep(time.Millisecond)
+	}
+
+	// wait until the source is watched
+	for {
+		src.Set([]byte(`{"message": "OTZ!"}`), "json")
+		select {
+		case val := <-w.C:
+			assert.Equal(&Data{Message: "OTZ!"}, val)
+		case <-time.After(10 * time.Millisecond):
+			continue
+		}
+		break
+	}
+	assert.Equal([]config.Change{{Path: "message", Old: "orz", New: "OTZ!"}}, <-changes)
+
+	assert.NoError(w.Close())
+	assert.NoError(<-done)
+}
@@ -0,0 +1,55 @@
+package config_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/wiryls/pkg/config"
+)
+
+func TestReaderWriterFS(t *testing.T) {
+	assert := assert.New(t)
+
+	type Data struct {
+		Message string `json:"message" yaml:"message" toml:"message"`
+	}
+
+	{ // writer then reader
+		for _, format := range []string{"json", ".yaml", "toml"} {
+			buf := new(bytes.Buffer)
+			assert.NoError(config.SaveWriter(&Data{"orz"}, buf, format), format)
+
+			out := Data{}
+			assert.NoError(config.LoadReader(&out, buf, format), format)
+			assert.Equal("orz", out.Message, format)
+		}
+	}
+	{ // unknown format
+		out := Data{}
+		err := config.LoadReader(&out, strings.NewReader("orz"), "ini")
+		assert.True(errors.Is(err, config.ErrDecoding))
+	}
+	{ // fs.FS
+		fsys := fstest.MapFS{
+			"conf/app.yaml": &fstest.MapFile{Data: []byte("message: yaml\n")},
+			"conf/app.json": &fstest.MapFile{Data: []byte(`{"message": "json"}`)},
+		}
+
+		out := Data{}
+		assert.NoError(config.LoadFS(&out, fsys, "conf/app.yaml"))
+		assert.Equal("yaml", out.Message)
+
+		path, err := config.LoadSomeFS(&out, fsys, []string{"conf/app.toml", "conf/app.json"})
+		assert.NoError(err)
+		assert.Equal("conf/app.json", path)
+		assert.Equal("json", out.Message)
+
+		err = config.LoadFS(&out, fsys, "conf/app.toml")
+		assert.True(errors.Is(err, config.ErrReading))
+	}
+}
@@ -0,0 +1,185 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"github.com/wiryls/pkg/errors/detail"
+)
+
+// OptionStrict rejects keys that do not match any field of the target in
+// every format. All of them are reported by an `*UnknownFieldsError`.
+func OptionStrict() Option {
+	return func(o *options) { o.strict = true }
+}
+
+// UnknownField is a key that matches no field.
+type UnknownField struct {
+	Path string
+	Position
+}
+
+// UnknownFieldsError is an error contains all unknown fields found in
+// strict mode. It is aliased to `ErrDecoding`.
+type UnknownFieldsError struct {
+	Name   string
+	Fields []UnknownField
+	detail.Detail
+}
+
+// Error override the error interface to custom message.
+func (e *UnknownFieldsError) Error() string {
+	list := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		list[i] = "`" + f.Path + "`"
+		if f.Line > 0 {
+			list[i] += " (" + f.Position.String() + ")"
+		}
+	}
+	return "unknown fields in `" + e.Name + "`: " + strings.Join(list, ", ")
+}
+
+var typeUnmarshalers = []reflect.Type{
+	typeTextUnmarshaler,
+	reflect.TypeOf((*json.Unmarshaler)(nil)).Elem(),
+	reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem(),
+	reflect.TypeOf((*toml.Unmarshaler)(nil)).Elem(),
+}
+
+// strict checks if bin of format tag has keys unknown to dst.
+//  - If positions is false, positions will not be reported.
+func (s Marshalers) strict(name, tag string, bin []byte, dst interface{}, positions bool) (err error) {
+	var tree map[string]interface{}
+	if err == nil {
+		tree, err = s.toTree(nameOf(tag), bin)
+	}
+
+	var list []UnknownField
+	if err == nil {
+		key := tag
+		if key == "yml" {
+			key = "yaml"
+		}
+		unknowns(reflect.TypeOf(dst), tree, key, nil, &list)
+	}
+
+	if err == nil && len(list) != 0 {
+		if positions {
+			where := locate(tag, bin)
+			for i := range list {
+				list[i].Position = where[list[i].Path]
+			}
+		}
+
+		e := &UnknownFieldsError{Name: name, Fields: list}
+		e.Detail = detail.Make(
+			e,
+			detail.FlagAlias(ErrDecoding),
+			detail.FlagStackTrace(1))
+		err = e
+	}
+
+	return
+}
+
+// unknowns collects keys in x that have no field in type t.
+func unknowns(t reflect.Type, x interface{}, tag string, path []string, out *[]UnknownField) {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil {
+		return
+	}
+	for _, u := range typeUnmarshalers {
+		if reflect.PtrTo(t).Implements(u) {
+			return
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := x.(map[string]interface{})
+		if !ok {
+			return
+		}
+
+		for _, k := range sortedKeys(m) {
+			next := append(path[:len(path):len(path)], k)
+			if f, ok := fieldOf(t, tag, k); ok {
+				unknowns(f, m[k], tag, next, out)
+			} else {
+				*out = append(*out, UnknownField{Path: strings.Join(next, ".")})
+			}
+		}
+
+	case reflect.Map:
+		if m, ok := x.(map[string]interface{}); ok {
+			for _, k := range sortedKeys(m) {
+				unknowns(t.Elem(), m[k], tag, append(path[:len(path):len(path)], k), out)
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		if l, ok := x.([]interface{}); ok {
+			for i, v := range l {
+				unknowns(t.Elem(), v, tag, append(path[:len(path):len(path)], strconv.Itoa(i)), out)
+			}
+		}
+	}
+}
+
+// fieldOf finds the type of the field matching key in struct type t, by
+// the struct tag of a format, or the field name as the format does.
+func fieldOf(t reflect.Type, tag, key string) (reflect.Type, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+
+		list := strings.Split(f.Tag.Get(tag), ",")
+		name, inline := list[0], false
+		for _, opt := range list[1:] {
+			inline = inline || opt == "inline"
+		}
+
+		e := f.Type
+		if e.Kind() == reflect.Ptr {
+			e = e.Elem()
+		}
+
+		switch {
+		case name == "-":
+		case inline || (f.Anonymous && name == "" && e.Kind() == reflect.Struct):
+			if ft, ok := fieldOf(e, tag, key); ok {
+				return ft, true
+			}
+		case f.PkgPath != "":
+		case name == "" && matches(tag, defaultKey(tag, f.Name), key):
+			return f.Type, true
+		case name != "" && matches(tag, name, key):
+			return f.Type, true
+		}
+	}
+	return nil, false
+}
+
+// defaultKey is the key of an untagged field in format tag. YAML uses the
+// lower case field name, while JSON and TOML use the field name itself.
+func defaultKey(tag, field string) string {
+	if tag == "yaml" {
+		return strings.ToLower(field)
+	}
+	return field
+}
+
+// matches checks if key matches name. JSON and TOML are case-insensitive,
+// while YAML is not.
+func matches(tag, name, key string) bool {
+	return name == key || (tag == "json" || tag == "toml") && strings.EqualFold(name, key)
+}
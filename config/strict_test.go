@@ -0,0 +1,105 @@
+package config_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/wiryls/pkg/config"
+)
+
+func TestStrict(t *testing.T) {
+	assert := assert.New(t)
+
+	type Server struct {
+		Host string `json:"host" yaml:"host" toml:"host"`
+		Port int    `json:"port" yaml:"port" toml:"port"`
+	}
+	type Data struct {
+		Name    string            `json:"name"    yaml:"name"    toml:"name"`
+		Servers []Server          `json:"servers" yaml:"servers" toml:"servers"`
+		Labels  map[string]string `json:"labels"  yaml:"labels"  toml:"labels"`
+		Extra   interface{}       `json:"extra"   yaml:"extra"   toml:"extra"`
+	}
+
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"config.json": "{\n  \"name\": \"orz\",\n  \"nmae\": \"orz\",\n  \"servers\": [\n    {\"host\": \"a\"},\n    {\"host\": \"b\", \"prot\": 80}\n  ],\n  \"extra\": {\"any\": 1}\n}\n",
+		"config.yaml": "name: orz\nnmae: orz\nservers:\n  - host: a\n  - host: b\n    prot: 80\nextra:\n  any: 1\n",
+		"config.toml": "name = \"orz\"\nnmae = \"orz\"\n\n[[servers]]\nhost = \"a\"\n\n[[servers]]\n  host = \"b\"\n  prot = 80\n\n[extra]\nany = 1\n",
+	}
+	positions := map[string][]config.Position{
+		"config.json": {{3, 3}, {6, 19}},
+		"config.yaml": {{2, 1}, {6, 5}},
+		"config.toml": {{2, 1}, {9, 3}},
+	}
+
+	for name, text := range files {
+		dst := filepath.Join(dir, name)
+		assert.NoError(ioutil.WriteFile(dst, []byte(text), 0644))
+
+		out := Data{}
+		assert.NoError(config.Load(&out, dst), name)
+
+		out = Data{}
+		err := config.Load(&out, dst, config.OptionStrict())
+		assert.True(errors.Is(err, config.ErrDecoding), name)
+
+		var e *config.UnknownFieldsError
+		if assert.True(errors.As(err, &e), name) {
+			assert.Equal([]config.UnknownField{
+				{Path: "nmae", Position: positions[name][0]},
+				{Path: "servers.1.prot", Position: positions[name][1]},
+			}, e.Fields, name)
+			assert.Contains(e.Error(), "`nmae` (line "+fmt.Sprint(positions[name][0].Line), name)
+		}
+	}
+	{ // clean
+		dst := filepath.Join(dir, "clean.json")
+		assert.NoError(ioutil.WriteFile(dst, []byte(`{"NAME": "orz", "labels": {"any": "1"}}`), 0644))
+
+		out := Data{}
+		assert.NoError(config.Load(&out, dst, config.OptionStrict()))
+		assert.Equal("orz", out.Name)
+	}
+	{ // fields match keys as each format does
+		type Plain struct {
+			Name string
+			Port int `json:"port" yaml:"port" toml:"port"`
+		}
+
+		dst := filepath.Join(dir, "plain.yaml")
+		assert.NoError(ioutil.WriteFile(dst, []byte("NAME: orz\nPORT: 80\n"), 0644))
+
+		out := Plain{}
+		err := config.Load(&out, dst, config.OptionStrict())
+		var e *config.UnknownFieldsError
+		if assert.True(errors.As(err, &e)) {
+			assert.Equal([]config.UnknownField{
+				{Path: "NAME", Position: config.Position{Line: 1, Column: 1}},
+				{Path: "PORT", Position: config.Position{Line: 2, Column: 1}},
+			}, e.Fields)
+		}
+
+		for name, text := range map[string]string{
+			"plain.json": `{"NAME": "orz", "PORT": 80}`,
+			"plain.toml": "NAME = \"orz\"\nPORT = 80\n",
+			"plain.yml":  "name: orz\nport: 80\n",
+		} {
+			dst := filepath.Join(dir, name)
+			assert.NoError(ioutil.WriteFile(dst, []byte(text), 0644))
+
+			out := Plain{}
+			assert.NoError(config.Load(&out, dst, config.OptionStrict()), name)
+			assert.Equal(Plain{Name: "orz", Port: 80}, out, name)
+		}
+	}
+}
@@ -0,0 +1,543 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/wiryls/pkg/errors/cerrors"
+	"github.com/wiryls/pkg/errors/wrap"
+)
+
+// Tree is a config loaded without knowing its type. Values are got or set
+// by field paths like `server.tls.cert`, where slice items use their
+// indexes like `servers.0.port`.
+//  - It keeps the order of keys when saving to formats allowing it, which
+//    are JSON and YAML. New keys are appended.
+//  - It is not safe for concurrent use.
+type Tree struct {
+	root map[string]interface{}
+	keys map[string][]string // order of keys of each map by path
+	from Marshalers
+	tag  string // format loaded from
+}
+
+// NewTree creates an empty `Tree`, which is saved by s.
+func (s Marshalers) NewTree() *Tree {
+	return &Tree{
+		root: map[string]interface{}{},
+		keys: map[string][]string{},
+		from: s,
+		tag:  "json",
+	}
+}
+
+// LoadTree loads src of any supported format into a `Tree`.
+//  - Includes, migrations and `OptionInterpolate` work as `Load` does.
+//  - Other options, which need the type of config, are ignored.
+func (s Marshalers) LoadTree(src string, opts ...Option) (t *Tree, err error) {
+	var bin []byte
+	if err == nil {
+		bin, err = ioutil.ReadFile(src)
+		err = wrap.MessageAliasStack(err, "cannot read `"+src+"`", ErrReading, 0)
+	}
+
+	o := collect(opts)
+	var tree map[string]interface{}
+	var tag string
+	if err == nil {
+		tree, tag, _, err = s.fromFileTree(src, bin, o)
+	}
+	if err == nil && o.interpolate {
+		err = interpolate(&tree)
+	}
+
+	if err == nil {
+		t = s.treeOf(tag, bin, tree)
+	}
+	return
+}
+
+// treeOf creates a `Tree` of tree, which is decoded from bin of format tag.
+func (s Marshalers) treeOf(tag string, bin []byte, tree map[string]interface{}) *Tree {
+	t := s.NewTree()
+	t.root = tree
+	t.tag = tag
+	t.order(tree, "", locate(tag, bin))
+	return t
+}
+
+// order records keys of maps in tree by their positions in the file.
+// Keys with unknown positions are sorted after the others.
+func (t *Tree) order(x interface{}, path string, where map[string]Position) {
+	switch v := x.(type) {
+	case map[string]interface{}:
+		keys := sortedKeys(v)
+		sort.SliceStable(keys, func(i, j int) bool {
+			a, b := where[join(path, keys[i])], where[join(path, keys[j])]
+			switch {
+			case a.Line == 0 || b.Line == 0:
+				return a.Line != 0 && b.Line == 0
+			case a.Line != b.Line:
+				return a.Line < b.Line
+			default:
+				return a.Column < b.Column
+			}
+		})
+		t.keys[path] = keys
+		for _, k := range keys {
+			t.order(v[k], join(path, k), where)
+		}
+	case []interface{}:
+		for i, e := range v {
+			t.order(e, join(path, strconv.Itoa(i)), where)
+		}
+	}
+}
+
+// Save writes the tree to dst by the Marshalers loaded or created it.
+func (t *Tree) Save(dst string, opts ...Option) error {
+	return t.from.Save(t.value(dst), dst, opts...)
+}
+
+// value returns what to encode for a file of name.
+func (t *Tree) value(name string) interface{} {
+	if strings.EqualFold(filepath.Ext(name), ".toml") {
+		// keys of tables are always sorted in TOML
+		return t.root
+	}
+	return t.ordered(t.root, "")
+}
+
+// ordered converts maps in x to ordered maps.
+func (t *Tree) ordered(x interface{}, path string) interface{} {
+	switch v := x.(type) {
+	case map[string]interface{}:
+		keys := t.keysOf(v, path)
+		m := orderedMap{keys: keys, vals: make([]interface{}, len(keys))}
+		for i, k := range keys {
+			m.vals[i] = t.ordered(v[k], join(path, k))
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			l[i] = t.ordered(e, join(path, strconv.Itoa(i)))
+		}
+		return l
+	default:
+		return v
+	}
+}
+
+// keysOf returns keys of m at path by the recorded order.
+func (t *Tree) keysOf(m map[string]interface{}, path string) []string {
+	keys := make([]string, 0, len(m))
+	seen := map[string]bool{}
+	for _, k := range t.keys[path] {
+		if _, ok := m[k]; ok && !seen[k] {
+			keys = append(keys, k)
+			seen[k] = true
+		}
+	}
+	for _, k := range sortedKeys(m) {
+		if !seen[k] {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// Get returns the value at path, which is a `map[string]interface{}`,
+// a `[]interface{}` or a scalar.
+func (t *Tree) Get(path string) (val interface{}, ok bool) {
+	val = t.root
+	for _, k := range split(path) {
+		switch v := val.(type) {
+		case map[string]interface{}:
+			val, ok = v[k]
+		case []interface{}:
+			var i int
+			i, ok = index(k, len(v))
+			if ok {
+				val = v[i]
+			}
+		default:
+			ok = false
+		}
+		if !ok {
+			return nil, false
+		}
+	}
+	return val, true
+}
+
+// Has tests if there is a value at path.
+func (t *Tree) Has(path string) bool {
+	_, ok := t.Get(path)
+	return ok
+}
+
+// GetString returns the value at path as a string. Scalars are formatted.
+//  - It returns def, or "" if no def, when the value is missing or is
+//    a map or slice.
+func (t *Tree) GetString(path string, def ...string) (out string) {
+	if len(def) != 0 {
+		out = def[0]
+	}
+	t.get(path, &out)
+	return
+}
+
+// GetInt returns the value at path as an int. Strings are parsed.
+//  - It returns def, or 0 if no def, when the value is missing or cannot
+//    be converted.
+func (t *Tree) GetInt(path string, def ...int) (out int) {
+	if len(def) != 0 {
+		out = def[0]
+	}
+	t.get(path, &out)
+	return
+}
+
+// GetFloat returns the value at path as a float64. Strings are parsed.
+//  - It returns def, or 0 if no def, when the value is missing or cannot
+//    be converted.
+func (t *Tree) GetFloat(path string, def ...float64) (out float64) {
+	if len(def) != 0 {
+		out = def[0]
+	}
+	t.get(path, &out)
+	return
+}
+
+// GetBool returns the value at path as a bool. Strings are parsed.
+//  - It returns def, or false if no def, when the value is missing or
+//    cannot be converted.
+func (t *Tree) GetBool(path string, def ...bool) (out bool) {
+	if len(def) != 0 {
+		out = def[0]
+	}
+	t.get(path, &out)
+	return
+}
+
+// GetDuration returns the value at path as a time.Duration. Strings are
+// parsed by `time.ParseDuration` and numbers are nanoseconds.
+//  - It returns def, or 0 if no def, when the value is missing or cannot
+//    be converted.
+func (t *Tree) GetDuration(path string, def ...time.Duration) (out time.Duration) {
+	if len(def) != 0 {
+		out = def[0]
+	}
+	if val, ok := t.Get(path); ok {
+		switch v := reflect.ValueOf(val); v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return time.Duration(v.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return time.Duration(v.Uint())
+		}
+	}
+	t.get(path, &out)
+	return
+}
+
+// GetStrings returns the value at path as a []string. A string is split
+// by ",".
+//  - It returns def when the value is missing or cannot be converted.
+func (t *Tree) GetStrings(path string, def ...string) []string {
+	out := def
+	if val, ok := t.Get(path); ok {
+		// a list or a string separated by ","
+		if list, ok := val.([]interface{}); ok {
+			strs := make([]string, len(list))
+			for i, e := range list {
+				if strs[i], ok = text(e); !ok {
+					return def
+				}
+			}
+			return strs
+		}
+		t.get(path, &out)
+	}
+	return out
+}
+
+// get converts the scalar at path into out. Nothing changes on failure.
+func (t *Tree) get(path string, out interface{}) {
+	val, ok := t.Get(path)
+	if !ok {
+		return
+	}
+
+	str, ok := text(val)
+	if !ok {
+		return
+	}
+
+	v := reflect.New(reflect.TypeOf(out).Elem()).Elem()
+	if parseText(v, str) == nil {
+		reflect.ValueOf(out).Elem().Set(v)
+	}
+}
+
+// text formats a scalar into a string that parseText could parse.
+func text(val interface{}) (string, bool) {
+	switch v := val.(type) {
+	case nil, map[string]interface{}, []interface{}:
+		return "", false
+	case string:
+		return v, true
+	case time.Time:
+		return v.Format(time.RFC3339Nano), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	default:
+		return fmt.Sprint(v), true
+	}
+}
+
+// Set stores val at path. Missing maps on the path are created.
+//  - An index of a slice must be in range, or be its length to append.
+//  - Values are normalized like loaded ones, such as `map[string]string`
+//    and structs are converted to `map[string]interface{}`.
+func (t *Tree) Set(path string, val interface{}) (err error) {
+	keys := split(path)
+	err = cerrors.TestInvalidArgument(len(keys) == 0, "path string", "must not be empty")
+
+	if err == nil {
+		val, err = generic(val)
+	}
+
+	if err == nil {
+		var next interface{}
+		next, err = t.set(t.root, keys, val, "")
+		if err == nil {
+			t.root = next.(map[string]interface{})
+		}
+	}
+	return
+}
+
+func (t *Tree) set(x interface{}, keys []string, val interface{}, path string) (interface{}, error) {
+	if len(keys) == 0 {
+		t.forget(path)
+		return val, nil
+	}
+
+	k, rest := keys[0], keys[1:]
+	switch v := x.(type) {
+	case []interface{}:
+		i, ok := index(k, len(v)+1)
+		if !ok {
+			return nil, cerrors.InvalidArgument(join(path, k), "index out of range")
+		}
+
+		var e interface{}
+		if i < len(v) {
+			e = v[i]
+		}
+		e, err := t.set(e, rest, val, join(path, k))
+		if err == nil && i == len(v) {
+			v = append(v, e)
+		} else if err == nil {
+			v[i] = e
+		}
+		return v, err
+
+	case map[string]interface{}:
+		e, err := t.set(v[k], rest, val, join(path, k))
+		if err == nil {
+			if _, ok := v[k]; !ok {
+				t.keys[path] = append(t.keys[path], k)
+			}
+			v[k] = e
+		}
+		return v, err
+
+	default:
+		m := map[string]interface{}{}
+		t.forget(path)
+		return t.set(m, keys, val, path)
+	}
+}
+
+// Delete removes the value at path. It returns false if it is missing.
+//  - An item removed from a slice shifts the following ones.
+func (t *Tree) Delete(path string) bool {
+	keys := split(path)
+	if len(keys) == 0 {
+		return false
+	}
+
+	parent, last := strings.Join(keys[:len(keys)-1], "."), keys[len(keys)-1]
+	x, ok := t.Get(parent)
+	if !ok {
+		return false
+	}
+
+	switch v := x.(type) {
+	case map[string]interface{}:
+		if _, ok = v[last]; ok {
+			delete(v, last)
+			t.forget(join(parent, last))
+		}
+	case []interface{}:
+		var i int
+		if i, ok = index(last, len(v)); ok {
+			// items after i are shifted, which is a new slice
+			_, _ = t.set(t.root, keys[:len(keys)-1], append(v[:i:i], v[i+1:]...), "")
+		}
+	default:
+		ok = false
+	}
+	return ok
+}
+
+// Keys returns keys of the map at path in order. It returns nil if there
+// is no map.
+func (t *Tree) Keys(path string) []string {
+	x, _ := t.Get(path)
+	if m, ok := x.(map[string]interface{}); ok {
+		return t.keysOf(m, path)
+	}
+	return nil
+}
+
+// Decode decodes the tree into dst, like loading from a file of the
+// format it is loaded from.
+func (t *Tree) Decode(dst interface{}, opts ...Option) (err error) {
+	err = cerrors.TestNilArgumentIfNoErr(err, dst, "dst interface{}")
+
+	o := collect(opts)
+	if err == nil {
+		err = t.from.fromTree(nameOf(t.tag), t.root, dst, o)
+	}
+	if err == nil {
+		err = o.overlay(dst)
+	}
+	return
+}
+
+// forget drops the recorded orders under path.
+func (t *Tree) forget(path string) {
+	for k := range t.keys {
+		if k == path || strings.HasPrefix(k, path+".") || path == "" {
+			delete(t.keys, k)
+		}
+	}
+}
+
+// generic converts val into a generic value by JSON.
+func generic(val interface{}) (interface{}, error) {
+	switch v := val.(type) {
+	case time.Duration:
+		return v.String(), nil
+	case int:
+		return int64(v), nil
+	case nil, string, bool, int64, float64, time.Time:
+		return v, nil
+	}
+
+	bin, err := json.Marshal(val)
+	if err == nil {
+		dec := json.NewDecoder(bytes.NewReader(bin))
+		dec.UseNumber()
+		err = dec.Decode(&val)
+	}
+	if err == nil {
+		val = numbers(val)
+	}
+	return val, wrap.MessageAliasStack(err, "cannot convert value", cerrors.ErrInvalidArgument, 0)
+}
+
+// numbers converts json.Number in x to int64 or float64.
+func numbers(x interface{}) interface{} {
+	switch v := x.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = numbers(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = numbers(e)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return x
+}
+
+// split splits a path into keys.
+func split(path string) []string {
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
+// index parses k as an index less than n.
+func index(k string, n int) (int, bool) {
+	i, err := strconv.Atoi(k)
+	return i, err == nil && i >= 0 && i < n
+}
+
+// orderedMap is a map that keeps the order of its keys while encoding.
+type orderedMap struct {
+	keys []string
+	vals []interface{}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (m orderedMap) MarshalJSON() ([]byte, error) {
+	buf := bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, k := range m.keys {
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		val, err := json.Marshal(m.vals[i])
+		if err != nil {
+			return nil, err
+		}
+
+		if i != 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (m orderedMap) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for i, k := range m.keys {
+		key, val := &yaml.Node{}, &yaml.Node{}
+		if err := key.Encode(k); err != nil {
+			return nil, err
+		}
+		if err := val.Encode(m.vals[i]); err != nil {
+			return nil, err
+		}
+		node.Content = append(node.Content, key, val)
+	}
+	return node, nil
+}
@@ -0,0 +1,168 @@
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/wiryls/pkg/config"
+)
+
+func TestTree(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"config.json": `{
+    "server": {"tls": {"cert": "a.pem"}, "port": "8080", "timeout": "5s"},
+    "name": "orz",
+    "tags": ["a", "b"],
+    "ratio": 0.5,
+    "debug": true
+}
+`,
+		"config.yaml": `server:
+    tls:
+        cert: a.pem
+    port: "8080"
+    timeout: 5s
+name: orz
+tags: [a, b]
+ratio: 0.5
+debug: true
+`,
+		"config.toml": `name = "orz"
+tags = ["a", "b"]
+ratio = 0.5
+debug = true
+
+[server]
+port = "8080"
+timeout = "5s"
+
+[server.tls]
+cert = "a.pem"
+`,
+	}
+
+	for name, text := range files {
+		src := filepath.Join(dir, name)
+		assert.NoError(ioutil.WriteFile(src, []byte(text), 0644))
+
+		tree, err := config.LoadTree(src)
+		if !assert.NoError(err, name) {
+			continue
+		}
+
+		{ // getters
+			assert.Equal("a.pem", tree.GetString("server.tls.cert"), name)
+			assert.Equal("b", tree.GetString("tags.1"), name)
+			assert.Equal("0.5", tree.GetString("ratio"), name)
+			assert.Equal("none", tree.GetString("server.tls.key", "none"), name)
+			assert.Equal("", tree.GetString("server.tls"), name)
+			assert.Equal(8080, tree.GetInt("server.port"), name)
+			assert.Equal(1, tree.GetInt("name", 1), name)
+			assert.Equal(5*time.Second, tree.GetDuration("server.timeout"), name)
+			assert.Equal(time.Second, tree.GetDuration("server.idle", time.Second), name)
+			assert.Equal(0.5, tree.GetFloat("ratio"), name)
+			assert.True(tree.GetBool("debug"), name)
+			assert.Equal([]string{"a", "b"}, tree.GetStrings("tags"), name)
+			assert.True(tree.Has("server.tls"), name)
+			assert.False(tree.Has("tags.2"), name)
+		}
+		{ // setters
+			assert.NoError(tree.Set("server.tls.key", "a.key"), name)
+			assert.NoError(tree.Set("server.timeout", 10*time.Second), name)
+			assert.NoError(tree.Set("tags.2", "c"), name)
+			assert.NoError(tree.Set("db", map[string]int{"port": 5432}), name)
+			assert.Error(tree.Set("tags.9", "x"), name)
+			assert.Error(tree.Set("", "x"), name)
+			assert.True(tree.Delete("debug"), name)
+			assert.True(tree.Delete("tags.0"), name)
+			assert.False(tree.Delete("debug"), name)
+
+			assert.Equal("a.key", tree.GetString("server.tls.key"), name)
+			assert.Equal(10*time.Second, tree.GetDuration("server.timeout"), name)
+			assert.Equal([]string{"b", "c"}, tree.GetStrings("tags"), name)
+			assert.Equal(5432, tree.GetInt("db.port"), name)
+			assert.False(tree.Has("debug"), name)
+		}
+		{ // save and reload
+			dst := filepath.Join(dir, "saved"+filepath.Ext(name))
+			assert.NoError(tree.Save(dst), name)
+
+			back, err := config.LoadTree(dst)
+			if assert.NoError(err, name) {
+				if filepath.Ext(name) != ".toml" {
+					assert.Equal(tree.Keys(""), back.Keys(""), name)
+				}
+				assert.Equal("a.key", back.GetString("server.tls.key"), name)
+				assert.Equal(5432, back.GetInt("db.port"), name)
+			}
+		}
+		{ // decode
+			type Data struct {
+				Name string   `json:"name" yaml:"name" toml:"name"`
+				Tags []string `json:"tags" yaml:"tags" toml:"tags"`
+			}
+
+			out := Data{}
+			assert.NoError(tree.Decode(&out), name)
+			assert.Equal(Data{Name: "orz", Tags: []string{"b", "c"}}, out, name)
+		}
+	}
+	{ // durations in nanoseconds
+		src := filepath.Join(dir, "timeout.yaml")
+		assert.NoError(ioutil.WriteFile(src, []byte("timeout: 5000000000\nidle: 1000\n"), 0644))
+
+		tree, err := config.LoadTree(src)
+		if assert.NoError(err) {
+			assert.Equal(5*time.Second, tree.GetDuration("timeout"))
+			assert.Equal(time.Microsecond, tree.GetDuration("idle"))
+		}
+
+		tree = config.NewTree()
+		assert.NoError(tree.Set("timeout", uint32(7)))
+		assert.Equal(7*time.Nanosecond, tree.GetDuration("timeout"))
+	}
+	{ // order
+		src := filepath.Join(dir, "config.yaml")
+		tree, err := config.LoadTree(src)
+		assert.NoError(err)
+		assert.Equal([]string{"server", "name", "tags", "ratio", "debug"}, tree.Keys(""))
+		assert.Equal([]string{"tls", "port", "timeout"}, tree.Keys("server"))
+
+		assert.NoError(tree.Set("aaa", 1))
+		assert.NoError(tree.Save(src))
+
+		bin, err := ioutil.ReadFile(src)
+		assert.NoError(err)
+		assert.Equal(`server:
+    tls:
+        cert: a.pem
+    port: "8080"
+    timeout: 5s
+name: orz
+tags:
+    - a
+    - b
+ratio: 0.5
+debug: true
+aaa: 1
+`, string(bin))
+	}
+	{ // new
+		tree := config.NewTree()
+		assert.NoError(tree.Set("b.c", "1"))
+		assert.NoError(tree.Set("a", []int{1, 2}))
+		assert.Equal([]string{"b", "a"}, tree.Keys(""))
+		assert.Equal(2, tree.GetInt("a.1"))
+	}
+}
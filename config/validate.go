@@ -0,0 +1,211 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/wiryls/pkg/errors/cerrors"
+	"github.com/wiryls/pkg/errors/detail"
+)
+
+// Validate checks the fields of v by their `validate` tags. It is invoked
+// by `Load`, `LoadSome` and `LoadOrSaveSome` after loading.
+//
+// Rules are separated by ",":
+//  - `required`: the field must not be zero.
+//  - `min=N`, `max=N`: bounds of numbers, or lengths of strings, slices and
+//    maps.
+//  - `oneof=a|b|c`: the field must be one of the values.
+//
+// Other rules are ignored, so that tags for other validators, such as
+// `validate:"email"`, do not break loading.
+//
+// All failures are collected into one `*ValidationError`.
+func Validate(v interface{}) (err error) {
+	err = cerrors.TestNilArgumentIfNoErr(err, v, "v interface{}")
+
+	if err == nil {
+		c := checker{}
+		c.visit(reflect.ValueOf(v), nil)
+		err = c.done()
+	}
+
+	return
+}
+
+// ValidationError is a list of `*cerrors.InvalidArgumentError`, each has a
+// field path in its `Argument`.
+//  - Please use `Validate` to create it.
+type ValidationError struct {
+	Errors []error
+	detail.Detail
+}
+
+// Error override the error interface to custom message.
+func (e *ValidationError) Error() string {
+	list := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		list[i] = err.Error()
+	}
+	return "invalid config: " + strings.Join(list, "; ")
+}
+
+// checker collects errors while validating.
+type checker struct {
+	errs []error
+}
+
+func (c *checker) done() error {
+	if len(c.errs) == 0 {
+		return nil
+	}
+
+	err := &ValidationError{Errors: c.errs}
+	err.Detail = detail.Make(
+		err,
+		detail.FlagAlias(cerrors.ErrInvalidArgument),
+		detail.FlagInner(c.errs[0]),
+		detail.FlagStackTrace(2))
+	return err
+}
+
+func (c *checker) fail(path []string, reason string) {
+	c.errs = append(c.errs, cerrors.InvalidArgument(strings.Join(path, "."), reason))
+}
+
+func (c *checker) visit(v reflect.Value, path []string) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			switch name := fieldName(f); {
+			case isInline(f):
+				c.visit(v.Field(i), path)
+			case name != "":
+				next := append(path[:len(path):len(path)], name)
+				if tag := f.Tag.Get("validate"); tag != "" && tag != "-" {
+					c.check(v.Field(i), next, tag)
+				}
+				c.visit(v.Field(i), next)
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			c.visit(v.Index(i), append(path[:len(path):len(path)], strconv.Itoa(i)))
+		}
+	}
+}
+
+func (c *checker) check(v reflect.Value, path []string, tag string) {
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg := strings.TrimSpace(rule), ""
+		if i := strings.Index(name, "="); i >= 0 {
+			name, arg = name[:i], name[i+1:]
+		}
+
+		if name == "required" {
+			if v.IsZero() {
+				c.fail(path, "is required")
+			}
+			continue
+		}
+
+		// other rules ignore nil pointers
+		e := v
+		for e.Kind() == reflect.Ptr {
+			if e.IsNil() {
+				break
+			}
+			e = e.Elem()
+		}
+		if e.Kind() == reflect.Ptr {
+			continue
+		}
+
+		switch name {
+		case "min", "max":
+			c.bound(e, path, name, arg)
+		case "oneof":
+			list := strings.Split(arg, "|")
+			text := fmt.Sprint(e.Interface())
+			find := false
+			for _, item := range list {
+				find = find || item == text
+			}
+			if !find {
+				c.fail(path, "must be one of "+strings.Join(list, ", "))
+			}
+		default:
+			// rules of other validators
+		}
+	}
+}
+
+func (c *checker) bound(v reflect.Value, path []string, name, arg string) {
+	var cmp int
+	var what string
+
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			c.fail(path, "has an invalid rule `"+name+"="+arg+"`")
+			return
+		}
+		cmp, what = compare(float64(v.Len()), float64(n)), "length "
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		b := reflect.New(v.Type()).Elem()
+		if err := parseText(b, arg); err != nil {
+			c.fail(path, "has an invalid rule `"+name+"="+arg+"`")
+			return
+		}
+		cmp = compare(number(v), number(b))
+
+	default:
+		c.fail(path, "does not support rule `"+name+"`")
+		return
+	}
+
+	switch {
+	case name == "min" && cmp < 0:
+		c.fail(path, what+"must be at least "+arg)
+	case name == "max" && cmp > 0:
+		c.fail(path, what+"must be at most "+arg)
+	}
+}
+
+func number(v reflect.Value) float64 {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint())
+	default:
+		return v.Float()
+	}
+}
+
+func compare(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,101 @@
+package config_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/wiryls/pkg/config"
+	"github.com/wiryls/pkg/errors/cerrors"
+)
+
+func TestValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	type Server struct {
+		Host    string        `json:"host" validate:"required,hostname"`
+		Port    int           `json:"port" validate:"min=1,max=65535"`
+		Timeout time.Duration `json:"timeout" validate:"max=1m"`
+	}
+	type Data struct {
+		Level   string   `json:"level" validate:"oneof=debug|info"`
+		Mail    string   `json:"mail" validate:"email"`
+		Tags    []string `json:"tags" validate:"max=2"`
+		Servers []Server `json:"servers" validate:"required"`
+		Backup  *Server  `json:"backup"`
+	}
+
+	{ // valid
+		in := Data{Level: "info", Servers: []Server{{Host: "localhost", Port: 80}}}
+		assert.NoError(config.Validate(&in))
+	}
+	{ // invalid
+		in := Data{
+			Level:   "warn",
+			Tags:    []string{"a", "b", "c"},
+			Servers: []Server{{Port: 80}, {Host: "localhost", Port: 65536, Timeout: time.Hour}},
+			Backup:  &Server{Host: "localhost"},
+		}
+
+		err := config.Validate(&in)
+		assert.True(errors.Is(err, cerrors.ErrInvalidArgument))
+
+		var v *config.ValidationError
+		assert.True(errors.As(err, &v))
+
+		paths := []string{}
+		for _, e := range v.Errors {
+			var a *cerrors.InvalidArgumentError
+			if assert.True(errors.As(e, &a)) {
+				paths = append(paths, a.Argument)
+			}
+		}
+		assert.Equal([]string{
+			"level",
+			"tags",
+			"servers.0.host",
+			"servers.1.port",
+			"servers.1.timeout",
+			"backup.port",
+		}, paths)
+	}
+	{ // after loading
+		tmp, err := ioutil.TempFile("", "*.json")
+		assert.NoError(err)
+
+		dst := tmp.Name()
+		tmp.Close()
+		defer os.Remove(dst)
+
+		assert.NoError(config.Save(&Data{Level: "warn"}, dst))
+
+		out := Data{}
+		err = config.Load(&out, dst)
+		assert.True(errors.Is(err, cerrors.ErrInvalidArgument))
+
+		_, err = config.LoadSome(&out, []string{dst})
+		assert.True(errors.Is(err, cerrors.ErrInvalidArgument))
+	}
+	{ // rules of other validators are ignored
+		type Contact struct {
+			Mail string `json:"mail" validate:"required,email"`
+		}
+
+		tmp, err := ioutil.TempFile("", "*.json")
+		assert.NoError(err)
+
+		dst := tmp.Name()
+		tmp.Close()
+		defer os.Remove(dst)
+
+		assert.NoError(config.Save(&Contact{Mail: "not a mail"}, dst))
+
+		out := Contact{}
+		assert.NoError(config.Load(&out, dst))
+		assert.Equal("not a mail", out.Mail)
+	}
+}
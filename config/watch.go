@@ -0,0 +1,205 @@
+package config
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/wiryls/pkg/errors/cerrors"
+	"github.com/wiryls/pkg/runner"
+)
+
+// Watch creates a `Watcher` that reloads src whenever it changes on disk.
+// Each time it decodes the file into a fresh value of the type that dst
+// points to. The dst itself is never modified, but a copy of it is the
+// first value to compare with.
+func (s Marshalers) Watch(dst interface{}, src string, opts ...Option) *Watcher {
+	w := &Watcher{
+		Interval: time.Second,
+		path:     src,
+		kind:     reflect.TypeOf(dst),
+		load: func(_ context.Context, val interface{}) error {
+			return s.Load(val, src, opts...)
+		},
+		last: make(chan interface{}, 1),
+	}
+	return w.init(dst)
+}
+
+// WatchSource creates a `Watcher` that reloads src whenever `Source.Watch`
+// notifies. The `Interval` of the `Watcher` is not used.
+func (s Marshalers) WatchSource(dst interface{}, src Source, opts ...Option) *Watcher {
+	w := &Watcher{
+		source: src,
+		kind:   reflect.TypeOf(dst),
+		load: func(ctx context.Context, val interface{}) error {
+			return s.LoadSource(ctx, val, src, opts...)
+		},
+		last: make(chan interface{}, 1),
+	}
+	return w.init(dst)
+}
+
+func (w *Watcher) init(dst interface{}) *Watcher {
+	if dst != nil {
+		w.prev = clone(reflect.ValueOf(dst)).Interface()
+	}
+	w.C = w.last
+	w.Bind(w)
+	return w
+}
+
+// Watcher polls the modification time and size of a config file, or
+// watches a `Source`, and reloads it if they change. Only values that
+// decode cleanly are handed to `OnChange`, or sent to `C` if `OnChange` is
+// nil.
+//
+// Functions registered by `Fields.Subscribe` are called with the `Diff`
+// between the previous and the reloaded value, before `OnChange`.
+//
+// It is a `runner.Runnable` bound to its own `runner.Determination`. Call
+// `Run` to start watching and `Close` to stop it.
+type Watcher struct {
+	runner.Determination
+
+	// Fields calls functions subscribed to field paths that change.
+	Fields Subscribers
+	// Interval between two polls. Default to one second.
+	Interval time.Duration
+	// OnChange is invoked with each reloaded value.
+	OnChange func(val interface{})
+	// OnError is invoked with errors during reloading. Optional.
+	OnError func(err error)
+	// C receives the latest reloaded value if `OnChange` is nil.
+	C <-chan interface{}
+
+	path   string
+	source Source
+	kind   reflect.Type
+	load   func(context.Context, interface{}) error
+	last   chan interface{}
+	prev   interface{}
+	stat   stamp
+}
+
+// stamp is what we compare to find changes.
+type stamp struct {
+	time time.Time
+	size int64
+	find bool
+}
+
+func (w *Watcher) stamp() (s stamp) {
+	if info, err := os.Stat(w.path); err == nil {
+		s = stamp{info.ModTime(), info.Size(), true}
+	}
+	return
+}
+
+// BeforeRunning records the current state of the file.
+func (w *Watcher) BeforeRunning(<-chan struct{}) error {
+	if w.source == nil {
+		w.stat = w.stamp()
+	}
+	return nil
+}
+
+// Running polls the file, or watches the source, until exit.
+func (w *Watcher) Running(exit <-chan struct{}) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-exit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if w.source != nil {
+		return w.watch(ctx)
+	}
+
+	interval := w.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-tick.C:
+			w.poll(ctx)
+		}
+	}
+}
+
+// watch reloads on notifications of the source until ctx is done.
+func (w *Watcher) watch(ctx context.Context) error {
+	c, err := w.source.Watch(ctx)
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case _, ok := <-c:
+			if !ok {
+				return nil
+			}
+			w.reload(ctx)
+		}
+	}
+}
+
+// AfterRunning does nothing.
+func (w *Watcher) AfterRunning() error { return nil }
+
+func (w *Watcher) poll(ctx context.Context) {
+	curr := w.stamp()
+	if curr == w.stat || !curr.find {
+		return
+	}
+	w.stat = curr
+	w.reload(ctx)
+}
+
+func (w *Watcher) reload(ctx context.Context) {
+	var val interface{}
+	err := cerrors.TestInvalidArgument(
+		w.kind == nil || w.kind.Kind() != reflect.Ptr,
+		"dst interface{}", "a pointer is required")
+
+	if err == nil {
+		val = reflect.New(w.kind.Elem()).Interface()
+		err = w.load(ctx, val)
+	}
+
+	if err == nil {
+		w.Fields.Notify(Diff(w.prev, val))
+		w.prev = clone(reflect.ValueOf(val)).Interface()
+	}
+
+	switch {
+	case err != nil:
+		if w.OnError != nil {
+			w.OnError(err)
+		}
+	case w.OnChange != nil:
+		w.OnChange(val)
+	default:
+		// keep only the latest one
+		select {
+		case <-w.last:
+		default:
+		}
+		w.last <- val
+	}
+}
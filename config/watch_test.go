@@ -0,0 +1,78 @@
+package config_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/wiryls/pkg/config"
+	"github.com/wiryls/pkg/runner"
+)
+
+func TestWatch(t *testing.T) {
+	assert := assert.New(t)
+
+	type Data struct {
+		Message string `json:"message"`
+	}
+
+	tmp, err := ioutil.TempFile("", "*.json")
+	assert.NoError(err)
+
+	dst := tmp.Name()
+	tmp.Close()
+	defer os.Remove(dst)
+
+	in := Data{Message: "orz"}
+	assert.NoError(config.Save(&in, dst))
+
+	w := config.Watch(&in, dst)
+	w.Interval = 5 * time.Millisecond
+
+	fails := make(chan error, 1)
+	w.OnError = func(err error) { fails <- err }
+
+	changes := make(chan []config.Change, 1)
+	w.Fields.Subscribe("message", func(c []config.Change) { changes <- c })
+
+	var r runner.Runner = w
+	done := make(chan error)
+	go func() { done <- r.Run() }()
+	for w.State() != runner.StateRunning {
+		time.Sleep(time.Millisecond)
+	}
+
+	{ // a clean change
+		assert.NoError(config.Save(&Data{Message: "OTZ!"}, dst))
+		select {
+		case val := <-w.C:
+			assert.Equal(&Data{Message: "OTZ!"}, val)
+		case <-time.After(time.Second):
+			assert.Fail("timeout")
+		}
+		assert.Equal("orz", in.Message)
+		assert.Equal([]config.Change{{Path: "message", Old: "orz", New: "OTZ!"}}, <-changes)
+	}
+
+	{ // a broken change
+		assert.NoError(ioutil.WriteFile(dst, []byte("{orz"), 0644))
+		select {
+		case err := <-fails:
+			assert.True(errors.Is(err, config.ErrDecoding))
+		case <-time.After(time.Second):
+			assert.Fail("timeout")
+		}
+		select {
+		case val := <-w.C:
+			assert.Fail("unexpected value", val)
+		default:
+		}
+	}
+
+	assert.NoError(w.Close())
+	assert.NoError(<-done)
+}
@@ -3,6 +3,8 @@ module github.com/wiryls/pkg
 go 1.16
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/stretchr/testify v1.7.0
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -11,6 +11,8 @@ import (
 var (
 	ErrUnexpectedState = errors.New("unexpected state")
 	ErrRunnerIsClosing = errors.New("runner is closing")
+	ErrTooManyRestarts = errors.New("too many restarts")
+	ErrCloseTimeout    = errors.New("close timeout")
 )
 
 // UnexpectedStateError is an error when unexpected states happen.
@@ -36,6 +38,17 @@ func (e *UnexpectedStateError) Error() string {
 	return msg
 }
 
+// CloseTimeoutError is an error when a runner does not stop in time. The
+// runner keeps closing, and `State` is where it got stuck.
+type CloseTimeoutError struct {
+	State State
+	detail.Detail
+}
+
+func (e *CloseTimeoutError) Error() string {
+	return fmt.Sprintf("close timeout while %s", e.State)
+}
+
 // this struct is something like an internal namespace.
 type oops struct{}
 
@@ -50,3 +63,14 @@ func (oops) UnexpectedState(get State, expected ...State) error {
 		detail.FlagStackTrace(1))
 	return err
 }
+
+// CloseTimeout creates a CloseTimeoutError with the reason from ctx.
+func (oops) CloseTimeout(get State, reason error) error {
+	err := &CloseTimeoutError{State: get}
+	err.Detail = detail.Make(
+		err,
+		detail.FlagInner(reason),
+		detail.FlagAlias(ErrCloseTimeout),
+		detail.FlagStackTrace(1))
+	return err
+}
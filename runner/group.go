@@ -0,0 +1,215 @@
+package runner
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/wiryls/pkg/errors/cerrors"
+)
+
+// Group runs children with dependencies as a whole.
+//  - `BeforeRunning` of a child is invoked after those of its dependencies.
+//    Children without dependencies between them boot in parallel.
+//  - If a child fails to boot, children booted are closed by `AfterRunning`
+//    and the error is returned.
+//  - `Running` of all children are invoked together. If one of them
+//    returns an error, the others are asked to exit.
+//  - `AfterRunning` of a child is invoked after those depending on it.
+type Group struct {
+	Determination
+
+	members []member
+	index   map[Runnable]int
+	booted  []int
+}
+
+// member is a child and indexes of its dependencies.
+type member struct {
+	runn Runnable
+	deps []int
+}
+
+// NewGroup creates an empty `Group`.
+func NewGroup() *Group {
+	g := &Group{index: make(map[Runnable]int)}
+	g.Bind(g)
+	return g
+}
+
+// Add a child depending on deps, which must be added before. A child must
+// be comparable, usually a pointer, and could be added only once.
+//
+// WARNING: invoking it when state is not StateStopped may cause data race.
+func (g *Group) Add(child Runnable, deps ...Runnable) (err error) {
+	err = cerrors.TestNilArgumentIfNoErr(err, child, "child Runnable")
+
+	if err == nil {
+		err = cerrors.TestInvalidArgument(!comparable(child), "child Runnable", "must be comparable, such as a pointer")
+	}
+	if err == nil {
+		_, has := g.index[child]
+		err = cerrors.TestInvalidArgument(has, "child Runnable", "is added already")
+	}
+
+	m := member{runn: child}
+	for _, d := range deps {
+		if err == nil {
+			err = cerrors.TestInvalidArgument(d != nil && !comparable(d), "deps ...Runnable", "must be comparable, such as a pointer")
+		}
+		if err == nil {
+			i, has := g.index[d]
+			err = cerrors.TestInvalidArgument(!has, "deps ...Runnable", "must be added before")
+			m.deps = append(m.deps, i)
+		}
+	}
+
+	if err == nil {
+		g.index[child] = len(g.members)
+		g.members = append(g.members, m)
+	}
+
+	return
+}
+
+// comparable checks if r could be a key of maps.
+func comparable(r Runnable) bool {
+	return r != nil && reflect.TypeOf(r).Comparable()
+}
+
+// BeforeRunning boots children in the order of dependencies.
+func (g *Group) BeforeRunning(exit <-chan struct{}) (err error) {
+	all := make([]int, len(g.members))
+	for i := range all {
+		all[i] = i
+	}
+
+	g.booted, err = g.walk(all, false, true, func(r Runnable) error {
+		return r.BeforeRunning(exit)
+	})
+
+	if err != nil {
+		g.AfterRunning()
+	}
+	return
+}
+
+// Running runs all children until exit or one of them fails.
+func (g *Group) Running(exit <-chan struct{}) (err error) {
+	stop := make(chan struct{})
+	once := sync.Once{}
+	quit := func() { once.Do(func() { close(stop) }) }
+
+	go func() {
+		select {
+		case <-exit:
+			quit()
+		case <-stop:
+		}
+	}()
+
+	lock := sync.Mutex{}
+	wait := sync.WaitGroup{}
+	for _, i := range g.booted {
+		wait.Add(1)
+		go func(r Runnable) {
+			defer wait.Done()
+			if e := r.Running(stop); e != nil {
+				defer lock.Unlock()
+				/*_*/ lock.Lock()
+				if err == nil {
+					err = e
+				}
+				quit()
+			}
+		}(g.members[i].runn)
+	}
+
+	wait.Wait()
+	quit()
+	return
+}
+
+// AfterRunning closes booted children in the reverse order of dependencies,
+// and returns the first error.
+func (g *Group) AfterRunning() (err error) {
+	_, err = g.walk(g.booted, true, false, func(r Runnable) error {
+		return r.AfterRunning()
+	})
+	g.booted = nil
+	return
+}
+
+// walk invokes do with children in list in parallel. A child waits for its
+// dependencies, or those depending on it if reverse. If abort, children not
+// started are skipped after an error. It returns indexes of children done
+// without errors, and the first error.
+func (g *Group) walk(list []int, reverse, abort bool, do func(Runnable) error) (done []int, err error) {
+	ends := make(map[int]chan struct{}, len(list))
+	for _, i := range list {
+		ends[i] = make(chan struct{})
+	}
+
+	lock := sync.Mutex{}
+	fail := make(chan struct{})
+	once := sync.Once{}
+
+	wait := sync.WaitGroup{}
+	for _, i := range list {
+		wait.Add(1)
+		go func(i int) {
+			defer wait.Done()
+			defer close(ends[i])
+
+			for _, j := range g.waits(i, reverse) {
+				if c, has := ends[j]; has {
+					<-c
+				}
+			}
+
+			if abort {
+				select {
+				case <-fail:
+					return
+				default:
+				}
+			}
+
+			e := do(g.members[i].runn)
+
+			defer lock.Unlock()
+			/*_*/ lock.Lock()
+
+			switch {
+			case e == nil:
+				done = append(done, i)
+			case err == nil:
+				err = e
+				fallthrough
+			default:
+				if abort {
+					once.Do(func() { close(fail) })
+				}
+			}
+		}(i)
+	}
+
+	wait.Wait()
+	return
+}
+
+// waits returns children that i waits for.
+func (g *Group) waits(i int, reverse bool) (list []int) {
+	if !reverse {
+		return g.members[i].deps
+	}
+
+	for j := i + 1; j < len(g.members); j++ {
+		for _, d := range g.members[j].deps {
+			if d == i {
+				list = append(list, j)
+				break
+			}
+		}
+	}
+	return
+}
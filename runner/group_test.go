@@ -0,0 +1,141 @@
+package runner_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/wiryls/pkg/errors/cerrors"
+	"github.com/wiryls/pkg/runner"
+)
+
+// journal records events of steps.
+type journal struct {
+	lock sync.Mutex
+	list []string
+}
+
+func (j *journal) add(event string) {
+	defer j.lock.Unlock()
+	/*_*/ j.lock.Lock()
+	j.list = append(j.list, event)
+}
+
+func (j *journal) index(event string) int {
+	defer j.lock.Unlock()
+	/*_*/ j.lock.Lock()
+	for i, e := range j.list {
+		if e == event {
+			return i
+		}
+	}
+	return -1
+}
+
+// step is a child of groups.
+type step struct {
+	name string
+	book *journal
+	fail error
+	meet *sync.WaitGroup // wait for peers booting together
+}
+
+func (s *step) BeforeRunning(<-chan struct{}) error {
+	if s.meet != nil {
+		s.meet.Done()
+		met := make(chan struct{})
+		go func() { s.meet.Wait(); close(met) }()
+		select {
+		case <-met:
+		case <-time.After(time.Second):
+			return errors.New("not in parallel")
+		}
+	}
+	s.book.add("boot " + s.name)
+	return s.fail
+}
+
+func (s *step) Running(exit <-chan struct{}) error {
+	<-exit
+	return nil
+}
+
+func (s *step) AfterRunning() error {
+	s.book.add("close " + s.name)
+	return nil
+}
+
+// steps is a child that is not comparable.
+type steps []*step
+
+func (steps) BeforeRunning(<-chan struct{}) error { return nil }
+func (steps) Running(exit <-chan struct{}) error  { <-exit; return nil }
+func (steps) AfterRunning() error                 { return nil }
+
+func TestGroup(t *testing.T) {
+	assert := assert.New(t)
+
+	{ // ordered
+		book := &journal{}
+		meet := &sync.WaitGroup{}
+		meet.Add(2)
+		db := &step{name: "db", book: book, meet: meet}
+		mq := &step{name: "mq", book: book, meet: meet}
+		web := &step{name: "web", book: book}
+
+		g := runner.NewGroup()
+		assert.NoError(g.Add(db))
+		assert.NoError(g.Add(mq))
+		assert.NoError(g.Add(web, db, mq))
+		assert.Error(g.Add(web))
+		assert.Error(g.Add(&step{}, &step{}))
+		assert.Error(g.Add(nil))
+
+		done := make(chan error)
+		go func() { done <- g.Run() }()
+		for book.index("boot web") < 0 {
+			time.Sleep(time.Millisecond)
+		}
+
+		assert.Less(book.index("boot db"), book.index("boot web"))
+		assert.Less(book.index("boot mq"), book.index("boot web"))
+
+		assert.NoError(g.Close())
+		assert.NoError(<-done)
+		assert.Less(book.index("close web"), book.index("close db"))
+		assert.Less(book.index("close web"), book.index("close mq"))
+	}
+
+	{ // failed to boot
+		book := &journal{}
+		db := &step{name: "db", book: book}
+		web := &step{name: "web", book: book, fail: errors.New("oops")}
+		api := &step{name: "api", book: book}
+
+		g := runner.NewGroup()
+		assert.NoError(g.Add(db))
+		assert.NoError(g.Add(web, db))
+		assert.NoError(g.Add(api, web))
+
+		assert.EqualError(g.Run(), "oops")
+		assert.Equal([]string{"boot db", "boot web", "close db"}, book.list)
+	}
+
+	{ // not comparable
+		db := &step{name: "db", book: &journal{}}
+
+		g := runner.NewGroup()
+		assert.NoError(g.Add(db))
+		assert.True(errors.Is(g.Add(steps{db}), cerrors.ErrInvalidArgument))
+		assert.True(errors.Is(g.Add(&step{}, steps{db}), cerrors.ErrInvalidArgument))
+
+		var e *cerrors.InvalidArgumentError
+		assert.True(errors.As(g.Add(&step{}, steps{db}), &e))
+		assert.Equal("must be comparable, such as a pointer", e.Reason)
+		assert.True(errors.As(g.Add(&step{}, nil), &e))
+		assert.Equal("must be added before", e.Reason)
+	}
+}
@@ -1,5 +1,10 @@
 package runner
 
+import (
+	"context"
+	"time"
+)
+
 // Determination is a helper for building service like object.
 // Just create a `Determination` and bind it to a `Runnable`.
 type Determination struct {
@@ -25,6 +30,24 @@ func (s *Determination) State() State {
 	return s.stat.get()
 }
 
+// Subscribe returns a channel receiving transitions of states. Transitions
+// are dropped if the channel is full. Call `Unsubscribe` to release it.
+func (s *Determination) Subscribe() <-chan Transition {
+	return s.subscribe()
+}
+
+// Unsubscribe stops and closes a channel from `Subscribe`.
+func (s *Determination) Unsubscribe(c <-chan Transition) {
+	s.unsubscribe(c)
+}
+
+// WaitFor blocks until this `Runner` is in stat, and returns the `Err` of
+// the transition to it, such as the error of booting. It returns the error
+// of ctx if ctx is done first.
+func (s *Determination) WaitFor(ctx context.Context, stat State) error {
+	return s.waitfor(ctx, stat)
+}
+
 // Run this `Runner`.
 //  - Caller will be blocked until error happens or `Close` is called.
 func (s *Determination) Run() (err error) {
@@ -58,6 +81,18 @@ func (s *Determination) Close() error {
 	return s.close(s.trigger)
 }
 
+// CloseContext is `Close` but stops waiting when ctx is done. Then it
+// returns an `ErrCloseTimeout` with the state where the runner got stuck.
+// The runner keeps closing, and could be waited again.
+func (s *Determination) CloseContext(ctx context.Context) error {
+	return s.closecontext(ctx, s.trigger)
+}
+
+// CloseTimeout is `CloseContext` with a timeout.
+func (s *Determination) CloseTimeout(d time.Duration) error {
+	return s.closetimeout(d, s.trigger)
+}
+
 // BeforeRunning is a default do nothing method. If we create our object
 // like:
 //
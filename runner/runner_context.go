@@ -2,6 +2,7 @@ package runner
 
 import (
 	"context"
+	"time"
 )
 
 // DeterminationWithContext is a helper for building service like object.
@@ -36,6 +37,24 @@ func (s *DeterminationWithContext) State() State {
 	return s.stat.get()
 }
 
+// Subscribe returns a channel receiving transitions of states. Transitions
+// are dropped if the channel is full. Call `Unsubscribe` to release it.
+func (s *DeterminationWithContext) Subscribe() <-chan Transition {
+	return s.subscribe()
+}
+
+// Unsubscribe stops and closes a channel from `Subscribe`.
+func (s *DeterminationWithContext) Unsubscribe(c <-chan Transition) {
+	s.unsubscribe(c)
+}
+
+// WaitFor blocks until this `Runner` is in stat, and returns the `Err` of
+// the transition to it, such as the error of booting. It returns the error
+// of ctx if ctx is done first.
+func (s *DeterminationWithContext) WaitFor(ctx context.Context, stat State) error {
+	return s.waitfor(ctx, stat)
+}
+
 // Run this `Runner`.
 //  - Caller will be blocked until error happens or `Close` is called.
 func (s *DeterminationWithContext) Run() (err error) {
@@ -69,6 +88,18 @@ func (s *DeterminationWithContext) Close() error {
 	return s.close(s.trigger)
 }
 
+// CloseContext is `Close` but stops waiting when ctx is done. Then it
+// returns an `ErrCloseTimeout` with the state where the runner got stuck.
+// The runner keeps closing, and could be waited again.
+func (s *DeterminationWithContext) CloseContext(ctx context.Context) error {
+	return s.closecontext(ctx, s.trigger)
+}
+
+// CloseTimeout is `CloseContext` with a timeout.
+func (s *DeterminationWithContext) CloseTimeout(d time.Duration) error {
+	return s.closetimeout(d, s.trigger)
+}
+
 // BeforeRunning is a default do nothing method. If we create our object
 // like:
 //
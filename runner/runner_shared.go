@@ -1,7 +1,9 @@
 package runner
 
 import (
+	"context"
 	"sync"
+	"time"
 )
 
 type shared struct {
@@ -11,6 +13,11 @@ type shared struct {
 
 	lerr sync.Mutex
 	cerr error
+	last *exit // of the latest run
+
+	lsub sync.Mutex
+	subs map[chan Transition]struct{}
+	now  Transition // the latest one
 }
 
 func (s *shared) whilerunning(do func() error) (err error) {
@@ -28,37 +35,65 @@ func (s *shared) whilerunning(do func() error) (err error) {
 	return
 }
 
+// exit is the end of a run.
+type exit struct {
+	done chan struct{} // closed when `run` ends
+	cerr error         // error of closing
+}
+
 func (s *shared) closeasync(do func()) error {
+	_, err := s.notify(do)
+	return err
+}
+
+// notify looping to exit, and returns the end of the run.
+func (s *shared) notify(do func()) (*exit, error) {
 
 	// fast check stat
 	if s.stat.get() == StateStopped {
-		return whoops.UnexpectedState(StateStopped)
+		return nil, whoops.UnexpectedState(StateStopped)
 	}
 
-	// notify looping to exit.
-	s.lock.RLock()
+	defer s.lock.RUnlock()
+	/*_*/ s.lock.RLock()
+
 	if s.stat != StateStopped {
 		s.once.Do(do)
 	}
-	s.lock.RUnlock()
-
-	return nil
+	return s.last, nil
 }
 
 // Close this Determination and wait until stop running.
 //  - Use it from its `Callback` may cause deadlock. Please use
 //    `CloseAsync()` instead.
 func (s *shared) close(do func()) error {
+	return s.closecontext(context.Background(), do)
+}
 
-	err := s.closeasync(do)
+// closecontext is `close` which stops waiting if ctx is done. The runner
+// keeps closing, and it could be waited again.
+func (s *shared) closecontext(ctx context.Context, do func()) error {
+
+	x, err := s.notify(do)
 	if err != nil {
 		return err
 	}
 
 	// wait unitl `run` ends.
-	defer s.lerr.Unlock()
-	/*_*/ s.lerr.Lock()
-	return s.cerr
+	select {
+	case <-x.done:
+		err = x.cerr
+	case <-ctx.Done():
+		err = whoops.CloseTimeout(s.stat.get(), ctx.Err())
+	}
+	return err
+}
+
+// closetimeout is `closecontext` with a timeout.
+func (s *shared) closetimeout(d time.Duration, do func()) error {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return s.closecontext(ctx, do)
 }
 
 func (s *shared) run(
@@ -73,9 +108,14 @@ func (s *shared) run(
 	/*_*/ s.lerr.Lock()
 	/*_*/ s.cerr = nil
 
+	x := &exit{done: make(chan struct{})}
+	s.lock.Lock()
+	s.last = x
+	s.lock.Unlock()
+
 	// defer StateClosing -> StateStopped
 	defer s.lock.Unlock()
-	defer s.stat.set(StateStopped)
+	defer func() { s.move(StateStopped, err); x.cerr = s.cerr; close(x.done) }()
 	defer s.lock.Lock()
 
 	// StateStopped -> StateBooting -> StateRunning
@@ -90,7 +130,7 @@ func (s *shared) run(
 
 	// StateRunning -> StateClosing
 	if s.cerr == nil {
-		s.cerr = s.onClosing(trigger, closing)
+		s.cerr = s.onClosing(trigger, closing, err)
 	}
 
 	if err == nil {
@@ -113,8 +153,8 @@ func (s *shared) onBooting(prepare func(), booting func() error) (err error) {
 
 	if s.stat == StateStopped {
 		// [0] set stat
-		defer s.stat.set(StateRunning)
-		/*_*/ s.stat.set(StateBooting)
+		defer func() { s.move(StateRunning, err) }()
+		/*_*/ s.move(StateBooting, nil)
 
 		// [1] init
 		s.once = sync.Once{}
@@ -149,8 +189,8 @@ func (s *shared) onRunning(running func() error) (err error) {
 	return
 }
 
-// from StateRunning to StateClosing
-func (s *shared) onClosing(trigger func(), closing func() error) (err error) {
+// from StateRunning to StateClosing, because of cause
+func (s *shared) onClosing(trigger func(), closing func() error, cause error) (err error) {
 	// fast check stat
 	if stat := s.stat.get(); stat != StateRunning {
 		return whoops.UnexpectedState(s.stat, StateRunning)
@@ -162,7 +202,7 @@ func (s *shared) onClosing(trigger func(), closing func() error) (err error) {
 
 	if s.stat == StateRunning || s.stat == StateBooting {
 		// [0] stat
-		s.stat.set(StateClosing)
+		s.move(StateClosing, cause)
 
 		// [1] release
 		if trigger != nil {
@@ -177,3 +217,68 @@ func (s *shared) onClosing(trigger func(), closing func() error) (err error) {
 
 	return
 }
+
+// move to a new state and notify subscribers. Slow subscribers may miss
+// transitions.
+func (s *shared) move(to State, err error) {
+	t := Transition{From: s.stat.swap(to), To: to, Time: time.Now(), Err: err}
+
+	defer s.lsub.Unlock()
+	/*_*/ s.lsub.Lock()
+
+	s.now = t
+	for c := range s.subs {
+		select {
+		case c <- t:
+		default:
+		}
+	}
+}
+
+func (s *shared) subscribe() <-chan Transition {
+	defer s.lsub.Unlock()
+	/*_*/ s.lsub.Lock()
+
+	c := make(chan Transition, 16)
+	if s.subs == nil {
+		s.subs = make(map[chan Transition]struct{})
+	}
+	s.subs[c] = struct{}{}
+	return c
+}
+
+func (s *shared) unsubscribe(c <-chan Transition) {
+	defer s.lsub.Unlock()
+	/*_*/ s.lsub.Lock()
+
+	for k := range s.subs {
+		if k == c {
+			delete(s.subs, k)
+			close(k)
+		}
+	}
+}
+
+func (s *shared) waitfor(ctx context.Context, stat State) error {
+	c := s.subscribe()
+	defer s.unsubscribe(c)
+
+	s.lsub.Lock()
+	now := s.now
+	s.lsub.Unlock()
+
+	if now.To == stat {
+		return now.Err
+	}
+
+	for {
+		select {
+		case t := <-c:
+			if t.To == stat {
+				return t.Err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
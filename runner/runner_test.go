@@ -1,6 +1,9 @@
 package runner_test
 
 import (
+	"context"
+	"errors"
+	"runtime"
 	"testing"
 	"time"
 
@@ -179,3 +182,178 @@ func TestDummyService(t *testing.T) {
 		assert.Error(srv.HanldePing())
 	}
 }
+
+/////////////////////////////////////////////////////////////////////////////
+
+// stuck ignores exit until released.
+type stuck struct {
+	runner.Determination
+
+	release chan struct{}
+}
+
+func (s *stuck) Running(<-chan struct{}) error {
+	<-s.release
+	return nil
+}
+
+// waiting runs until exit.
+type waiting struct {
+	runner.DeterminationWithContext
+}
+
+func (w *waiting) BeforeRunning(context.Context) error { return nil }
+
+func (w *waiting) Running(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func TestCloseTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	{ // stuck in running
+		srv := &stuck{release: make(chan struct{})}
+		srv.Bind(srv)
+
+		c := make(chan error)
+		go func() { c <- srv.Run() }()
+		for srv.State() != runner.StateRunning {
+			time.Sleep(time.Millisecond)
+		}
+
+		err := srv.CloseTimeout(10 * time.Millisecond)
+		assert.True(errors.Is(err, runner.ErrCloseTimeout))
+		assert.True(errors.Is(err, context.DeadlineExceeded))
+
+		e := &runner.CloseTimeoutError{}
+		assert.True(errors.As(err, &e))
+		assert.Equal(runner.StateRunning, e.State)
+		assert.Equal(runner.StateRunning, srv.State())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.True(errors.Is(srv.CloseContext(ctx), runner.ErrCloseTimeout))
+
+		// no goroutine is left by calls timed out
+		n := runtime.NumGoroutine()
+		for i := 0; i < 100; i++ {
+			assert.Error(srv.CloseContext(ctx))
+		}
+		assert.Equal(n, runtime.NumGoroutine())
+
+		// wait again
+		close(srv.release)
+		assert.NoError(srv.CloseTimeout(time.Second))
+		assert.NoError(<-c)
+		assert.Equal(runner.StateStopped, srv.State())
+	}
+
+	{ // with context
+		srv := &waiting{}
+		srv.Bind(context.Background(), srv)
+		c := make(chan error)
+		go func() { c <- srv.Run() }()
+		for srv.State() != runner.StateRunning {
+			time.Sleep(time.Millisecond)
+		}
+
+		assert.NoError(srv.CloseTimeout(time.Second))
+		assert.NoError(<-c)
+		assert.Error(srv.CloseContext(context.Background()))
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	assert := assert.New(t)
+
+	srv := NewDummy().(*dummy)
+	c := srv.Subscribe()
+
+	done := make(chan error)
+	go func() { done <- srv.Run() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(srv.WaitFor(ctx, runner.StateRunning))
+	assert.NoError(srv.WaitFor(ctx, runner.StateRunning))
+	assert.NoError(srv.HanldePing())
+
+	assert.NoError(srv.Close())
+	assert.NoError(<-done)
+
+	expected := []runner.State{
+		runner.StateStopped,
+		runner.StateBooting,
+		runner.StateRunning,
+		runner.StateClosing,
+		runner.StateStopped,
+	}
+	for i := 1; i < len(expected); i++ {
+		t := <-c
+		assert.Equal(expected[i-1], t.From)
+		assert.Equal(expected[i], t.To)
+		assert.NoError(t.Err)
+		assert.False(t.Time.IsZero())
+	}
+
+	srv.Unsubscribe(c)
+	_, ok := <-c
+	assert.False(ok)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(context.DeadlineExceeded, srv.WaitFor(ctx, runner.StateRunning))
+}
+
+// failing fails to boot once released.
+type failing struct {
+	runner.Determination
+
+	release chan struct{}
+}
+
+func (f *failing) BeforeRunning(<-chan struct{}) error {
+	<-f.release
+	return errors.New("oops")
+}
+
+func (f *failing) Running(<-chan struct{}) error {
+	return nil
+}
+
+func TestWaitForFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	srv := &failing{release: make(chan struct{})}
+	srv.Bind(srv)
+	sub := srv.Subscribe()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	done := make(chan error)
+	go func() { done <- srv.Run() }()
+	assert.NoError(srv.WaitFor(ctx, runner.StateBooting))
+
+	// either subscribed before stopping or not, it is the same
+	wait := make(chan error)
+	go func() { wait <- srv.WaitFor(ctx, runner.StateStopped) }()
+	close(srv.release)
+
+	assert.EqualError(<-done, "oops")
+	assert.EqualError(<-wait, "oops")
+
+	errs := map[runner.State]string{}
+	for i := 0; i < 4; i++ {
+		t := <-sub
+		if t.Err != nil {
+			errs[t.To] = t.Err.Error()
+		}
+	}
+	assert.Equal(map[runner.State]string{
+		runner.StateRunning: "oops",
+		runner.StateClosing: "oops",
+		runner.StateStopped: "oops",
+	}, errs)
+}
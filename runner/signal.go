@@ -0,0 +1,150 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+)
+
+// RunUntilSignal runs r until one of sigs arrives, SIGINT and SIGTERM by
+// default, and returns what `Run` returns. It is `Signals.Run` with sigs.
+func RunUntilSignal(r Runner, sigs ...os.Signal) error {
+	s := Signals{Close: sigs}
+	return s.Run(r)
+}
+
+// Signals runs a `Runner` until signals arrive.
+//  - On the first signal to close, the runner is closed asynchronously. A
+//    runner not running yet is closed once it runs, if it has `WaitFor`.
+//  - On the second one, stacks of all goroutines are printed to stderr and
+//    the process exits with code 1.
+//  - On each SIGHUP, `OnReload` is invoked if it is not nil.
+type Signals struct {
+	// Close lists signals to close the runner. Default to SIGINT and
+	// SIGTERM.
+	Close []os.Signal
+	// OnReload is invoked on SIGHUP in its own goroutine. SIGHUPs arrived
+	// during a reload are merged into one more reload. Optional.
+	OnReload func() error
+	// OnError is invoked with errors of `OnReload`. Optional.
+	OnError func(err error)
+	// C is read for signals instead of those notified by the OS. Optional.
+	C <-chan os.Signal
+}
+
+// Run r until signals arrive, and returns what `Run` of r returns.
+func (s *Signals) Run(r Runner) error {
+	closing := s.Close
+	if len(closing) == 0 {
+		closing = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+
+	c := s.C
+	if c == nil {
+		sigs := closing
+		if s.OnReload != nil {
+			sigs = append(sigs[:len(sigs):len(sigs)], syscall.SIGHUP)
+		}
+
+		n := make(chan os.Signal, 2)
+		signal.Notify(n, sigs...)
+		defer signal.Stop(n)
+		c = n
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reload := make(chan struct{}, 1)
+	if s.OnReload != nil {
+		go s.reloading(ctx, reload)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- r.Run() }()
+
+	for count := 0; ; {
+		select {
+		case err := <-done:
+			return err
+
+		case sig := <-c:
+			switch {
+			case sig == syscall.SIGHUP && s.OnReload != nil:
+				select {
+				case reload <- struct{}{}:
+				default:
+				}
+
+			case !contains(closing, sig):
+			case count == 0:
+				count++
+				go closeWhenRunning(ctx, r)
+			default:
+				forceExit(sig)
+			}
+		}
+	}
+}
+
+// reloading invokes `OnReload` once for each message of reload until ctx
+// is done.
+func (s *Signals) reloading(ctx context.Context, reload <-chan struct{}) {
+	for {
+		select {
+		case <-reload:
+			if err := s.OnReload(); err != nil && s.OnError != nil {
+				s.OnError(err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// closeWhenRunning waits for r to run if it could, and then closes it
+// without waiting for it to stop.
+func closeWhenRunning(ctx context.Context, r Runner) {
+	if w, ok := r.(interface {
+		WaitFor(context.Context, State) error
+	}); ok {
+		// a runner failed to boot is running with an error
+		if w.WaitFor(ctx, StateRunning); ctx.Err() != nil {
+			return
+		}
+	}
+
+	if c, ok := r.(interface{ CloseAsync() error }); ok {
+		c.CloseAsync()
+	} else {
+		r.Close()
+	}
+}
+
+func contains(list []os.Signal, sig os.Signal) bool {
+	for _, s := range list {
+		if s == sig {
+			return true
+		}
+	}
+	return false
+}
+
+// forceExit reports goroutines still running and exits.
+func forceExit(sig os.Signal) {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	fmt.Fprintf(os.Stderr, "force exit on %v, goroutines still running:\n\n%s\n", sig, buf)
+	os.Exit(1)
+}
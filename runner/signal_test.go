@@ -0,0 +1,107 @@
+package runner_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/wiryls/pkg/runner"
+)
+
+// idle runs until exit.
+type idle struct {
+	runner.Determination
+}
+
+func (i *idle) Running(exit <-chan struct{}) error {
+	<-exit
+	return nil
+}
+
+// late starts running after start is closed.
+type late struct {
+	idle
+
+	start chan struct{}
+}
+
+func (l *late) Run() error {
+	<-l.start
+	return l.idle.Run()
+}
+
+func TestSignals(t *testing.T) {
+	assert := assert.New(t)
+
+	r := &idle{}
+	r.Bind(r)
+
+	c := make(chan os.Signal)
+	reloads := make(chan int)
+	failure := make(chan error)
+	count := 0
+	s := runner.Signals{
+		C: c,
+		OnReload: func() error {
+			count++
+			reloads <- count
+			if count > 1 {
+				return errors.New("reloaded already")
+			}
+			return nil
+		},
+		OnError: func(err error) { failure <- err },
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	done := make(chan error)
+	go func() { done <- s.Run(r) }()
+	assert.NoError(r.WaitFor(ctx, runner.StateRunning))
+
+	c <- syscall.SIGHUP
+	assert.Equal(1, <-reloads)
+	c <- syscall.SIGHUP
+	assert.Equal(2, <-reloads)
+	assert.EqualError(<-failure, "reloaded already")
+
+	c <- os.Kill // ignored
+	c <- os.Interrupt
+	assert.NoError(<-done)
+	assert.Equal(runner.StateStopped, r.State())
+}
+
+func TestSignalsBeforeRunning(t *testing.T) {
+	assert := assert.New(t)
+
+	r := &late{start: make(chan struct{})}
+	r.Bind(&r.idle)
+	sub := r.Subscribe()
+
+	c := make(chan os.Signal)
+	s := runner.Signals{C: c}
+
+	done := make(chan error)
+	go func() { done <- s.Run(r) }()
+
+	// received before running
+	c <- syscall.SIGTERM
+	assert.Equal(runner.StateStopped, r.State())
+	close(r.start)
+
+	assert.NoError(<-done)
+	for _, want := range []runner.State{
+		runner.StateBooting,
+		runner.StateRunning,
+		runner.StateClosing,
+		runner.StateStopped,
+	} {
+		assert.Equal(want, (<-sub).To)
+	}
+}
@@ -3,6 +3,7 @@ package runner
 import (
 	"strconv"
 	"sync/atomic"
+	"time"
 )
 
 // State of runner.
@@ -16,6 +17,16 @@ const (
 	StateClosing
 )
 
+// Transition of a runner from one state to another.
+type Transition struct {
+	From State
+	To   State
+	Time time.Time
+	// Err is the error of booting to StateRunning, of running to
+	// StateClosing, or of `Run` to StateStopped.
+	Err error
+}
+
 // StateToString convert state to string.
 func (s State) String() string {
 	switch s {
@@ -36,6 +47,10 @@ func (s *State) set(x State) {
 	atomic.StoreUint32((*uint32)(s), uint32(x))
 }
 
+func (s *State) swap(x State) State {
+	return State(atomic.SwapUint32((*uint32)(s), uint32(x)))
+}
+
 func (s *State) get() State {
 	return State(atomic.LoadUint32((*uint32)(s)))
 }
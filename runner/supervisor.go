@@ -0,0 +1,225 @@
+package runner
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/wiryls/pkg/errors/wrap"
+)
+
+// Strategy decides which children a `Supervisor` restarts if one fails.
+type Strategy uint32
+
+// Supervisor Strategies.
+const (
+	// OneForOne restarts the failed child only.
+	OneForOne Strategy = iota
+	// OneForAll restarts all running children with the failed one.
+	OneForAll
+	// RestForOne restarts the failed child and running children after it.
+	RestForOne
+)
+
+// String of the strategy.
+func (s Strategy) String() string {
+	switch s {
+	case OneForOne:
+		return "one-for-one"
+	case OneForAll:
+		return "one-for-all"
+	case RestForOne:
+		return "rest-for-one"
+	default:
+		return "unknown (" + strconv.Itoa(int(s)) + ")"
+	}
+}
+
+// Supervisor runs children and restarts them if their `Run` returns an
+// error. Children that return nil are not restarted.
+//  - Children are started by order, and closed by reverse order.
+//  - If it restarts more than `MaxRestarts` times within `Window`, it
+//    closes all children and its `Run` returns an `ErrTooManyRestarts`.
+//  - It is a `Runner` itself, so that supervisors could be nested.
+type Supervisor struct {
+	Determination
+
+	// Strategy to restart children.
+	Strategy Strategy
+	// MaxRestarts within Window. Default to 3 in 5 seconds.
+	MaxRestarts int
+	Window      time.Duration
+	// Backoff before a restart. It doubles for each restart within
+	// Window, up to MaxBackoff. Default to 100ms and 10s.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// OnError is invoked with each failed child and its error. Optional.
+	OnError func(child Runner, err error)
+
+	children []Runner
+}
+
+// NewSupervisor creates a `Supervisor` of children.
+//  - Children should not be run or closed by others.
+func NewSupervisor(strategy Strategy, children ...Runner) *Supervisor {
+	s := &Supervisor{
+		Strategy:    strategy,
+		MaxRestarts: 3,
+		Window:      5 * time.Second,
+		Backoff:     100 * time.Millisecond,
+		MaxBackoff:  10 * time.Second,
+		children:    append([]Runner(nil), children...),
+	}
+	s.Bind(s)
+	return s
+}
+
+// Children of this supervisor.
+func (s *Supervisor) Children() []Runner {
+	return append([]Runner(nil), s.children...)
+}
+
+// Running starts children, and restarts them until exit.
+func (s *Supervisor) Running(exit <-chan struct{}) error {
+	kids := make([]supervised, len(s.children))
+	ends := make(chan exited)
+	quit := make(chan struct{})
+	defer close(quit)
+
+	start := func(i int) {
+		done := make(chan struct{})
+		kids[i].done = done
+		go func(r Runner) {
+			err := r.Run()
+			close(done)
+			select {
+			case ends <- exited{i, done, err}:
+			case <-quit:
+			}
+		}(kids[i].runner)
+	}
+
+	for i := range kids {
+		kids[i].runner = s.children[i]
+		start(i)
+	}
+	defer func() {
+		for i := len(kids) - 1; i >= 0; i-- {
+			kids[i].stop()
+		}
+	}()
+
+	restarts := []time.Time{}
+	for {
+		var e exited
+		select {
+		case <-exit:
+			return nil
+		case e = <-ends:
+		}
+
+		// ignore stopped ones and clean exits
+		if kids[e.index].done != e.done {
+			continue
+		}
+		kids[e.index].done = nil
+		if e.err == nil {
+			continue
+		}
+		if s.OnError != nil {
+			s.OnError(kids[e.index].runner, e.err)
+		}
+
+		// count restarts within the window
+		now := time.Now()
+		window := s.Window
+		if window <= 0 {
+			window = 5 * time.Second
+		}
+		for len(restarts) > 0 && now.Sub(restarts[0]) > window {
+			restarts = restarts[1:]
+		}
+		limit := s.MaxRestarts
+		if limit <= 0 {
+			limit = 3
+		}
+		if len(restarts) >= limit {
+			return wrap.MessageAliasStack(e.err, "give up restarting after "+strconv.Itoa(len(restarts))+" times", ErrTooManyRestarts, 0)
+		}
+		delay := s.backoff(len(restarts))
+		restarts = append(restarts, now)
+
+		// stop others by the strategy
+		list, from := []int{e.index}, len(kids)
+		switch s.Strategy {
+		case OneForAll:
+			from = 0
+		case RestForOne:
+			from = e.index + 1
+		}
+		for i := len(kids) - 1; i >= from; i-- {
+			if kids[i].done != nil {
+				kids[i].stop()
+				list = append(list, i)
+			}
+		}
+		sort.Ints(list)
+
+		select {
+		case <-exit:
+			return nil
+		case <-time.After(delay):
+		}
+		for _, i := range list {
+			start(i)
+		}
+	}
+}
+
+// backoff returns the delay before the n-th restart within the window.
+func (s *Supervisor) backoff(n int) time.Duration {
+	delay, limit := s.Backoff, s.MaxBackoff
+	if delay <= 0 {
+		delay = 100 * time.Millisecond
+	}
+	if limit <= 0 {
+		limit = 10 * time.Second
+	}
+	for ; n > 0 && delay < limit; n-- {
+		delay *= 2
+	}
+	if delay > limit {
+		delay = limit
+	}
+	return delay
+}
+
+// supervised is a child and the signal of its end, nil if not running.
+type supervised struct {
+	runner Runner
+	done   chan struct{}
+}
+
+// exited is sent when `Run` of a child returns.
+type exited struct {
+	index int
+	done  chan struct{}
+	err   error
+}
+
+// stop closes the child and waits until its `Run` returns. It retries,
+// because `Close` fails if the child is not booting yet.
+func (s *supervised) stop() {
+	if s.done == nil {
+		return
+	}
+	for {
+		s.runner.Close()
+		select {
+		case <-s.done:
+			s.done = nil
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
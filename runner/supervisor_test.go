@@ -0,0 +1,121 @@
+package runner_test
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/wiryls/pkg/runner"
+)
+
+// flaky fails its first few runs.
+type flaky struct {
+	runner.Determination
+
+	runs  int32
+	fails int32
+}
+
+func newFlaky(fails int32) *flaky {
+	f := &flaky{fails: fails}
+	f.Bind(f)
+	return f
+}
+
+func (f *flaky) Runs() int32 {
+	return atomic.LoadInt32(&f.runs)
+}
+
+func (f *flaky) Running(exit <-chan struct{}) error {
+	if atomic.AddInt32(&f.runs, 1) <= f.fails {
+		return errors.New("flaky")
+	}
+	<-exit
+	return nil
+}
+
+// eventually waits until cond is true or a second passes.
+func eventually(cond func() bool) bool {
+	for end := time.Now().Add(time.Second); time.Now().Before(end); {
+		if cond() {
+			return true
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return cond()
+}
+
+func TestSupervisor(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, c := range []struct {
+		strategy runner.Strategy
+		expected [3]int32
+	}{
+		{runner.OneForOne, [3]int32{1, 2, 1}},
+		{runner.OneForAll, [3]int32{2, 2, 2}},
+		{runner.RestForOne, [3]int32{1, 2, 2}},
+	} {
+		a, b, x := newFlaky(0), newFlaky(1), newFlaky(0)
+		s := runner.NewSupervisor(c.strategy, a, b, x)
+		s.Backoff = time.Millisecond
+
+		fails := int32(0)
+		s.OnError = func(child runner.Runner, err error) {
+			assert.Equal(b, child)
+			atomic.AddInt32(&fails, 1)
+		}
+
+		done := make(chan error)
+		go func() { done <- s.Run() }()
+
+		assert.True(eventually(func() bool {
+			return [3]int32{a.Runs(), b.Runs(), x.Runs()} == c.expected &&
+				a.State() == runner.StateRunning &&
+				b.State() == runner.StateRunning &&
+				x.State() == runner.StateRunning
+		}), c.strategy.String())
+		assert.EqualValues(1, atomic.LoadInt32(&fails))
+
+		assert.NoError(s.Close())
+		assert.NoError(<-done)
+		assert.Equal(runner.StateStopped, a.State())
+		assert.Equal(runner.StateStopped, b.State())
+		assert.Equal(runner.StateStopped, x.State())
+	}
+
+	{ // too many restarts
+		a, b := newFlaky(0), newFlaky(100)
+		s := runner.NewSupervisor(runner.OneForOne, a, b)
+		s.Backoff = time.Millisecond
+		s.MaxRestarts = 2
+
+		err := s.Run()
+		assert.True(errors.Is(err, runner.ErrTooManyRestarts))
+		assert.EqualValues(3, b.Runs())
+		assert.Equal(runner.StateStopped, a.State())
+	}
+
+	{ // nested
+		a, b := newFlaky(0), newFlaky(1)
+		inner := runner.NewSupervisor(runner.OneForOne, b)
+		inner.Backoff = time.Millisecond
+		outer := runner.NewSupervisor(runner.OneForAll, a, inner)
+
+		done := make(chan error)
+		go func() { done <- outer.Run() }()
+
+		assert.True(eventually(func() bool {
+			return b.Runs() == 2 && b.State() == runner.StateRunning && a.State() == runner.StateRunning
+		}))
+		assert.EqualValues(1, a.Runs())
+
+		assert.NoError(outer.Close())
+		assert.NoError(<-done)
+		assert.Equal(runner.StateStopped, inner.State())
+		assert.Equal(runner.StateStopped, b.State())
+	}
+}